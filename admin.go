@@ -6,12 +6,14 @@
 package cloudinary
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 const (
@@ -19,24 +21,39 @@ const (
 )
 
 const (
-	pathListAllImages = "/resources/image"
-	pathListAllRaws   = "/resources/raw"
+	pathPing            = "/ping"
+	pathListAllImages   = "/resources/image"
+	pathListAllRaws     = "/resources/raw"
+	pathListAllVideos   = "/resources/video"
+	pathTags            = "/tags"
+	pathTransformations = "/transformations"
+	pathUploadPresets   = "/upload_presets"
 )
 
 const (
-	maxResults = 2048
+	maxResults      = 2048
+	maxPageResults  = 500 // Max results per page allowed by the admin API
+	deleteBatchSize = 100 // Max public ids per delete request
 )
 
+// resourcesPath returns the admin API path to the resources of type rtype.
+func resourcesPath(rtype ResourceType) string {
+	switch rtype {
+	case RawType:
+		return pathListAllRaws
+	case VideoType:
+		return pathListAllVideos
+	}
+	return pathListAllImages
+}
+
 func (s *Service) dropAllResources(rtype ResourceType, w io.Writer) error {
 	qs := url.Values{
 		"max_results": []string{strconv.FormatInt(maxResults, 10)},
 	}
-	path := pathListAllImages
-	if rtype == RawType {
-		path = pathListAllRaws
-	}
+	path := resourcesPath(rtype)
 	for {
-		resp, err := http.Get(fmt.Sprintf("%s%s?%s", s.adminURI, path, qs.Encode()))
+		resp, err := s.get(opAdmin, fmt.Sprintf("%s%s?%s", s.adminURI, path, qs.Encode()))
 		m, err := handleHttpResponse(resp)
 		if err != nil {
 			return err
@@ -85,31 +102,172 @@ func (s *Service) DropAll(w io.Writer) error {
 	return nil
 }
 
-func (s *Service) doGetResources(rtype ResourceType) ([]*Resource, error) {
+// DeleteAll deletes all remote resources of type rtype using the bulk
+// delete API, in batches of 100 public ids. Nothing is deleted unless
+// confirm is true; otherwise ErrNotConfirmed is returned. Resources
+// matching the KeepFiles() pattern are left untouched.
+//
+// It returns the number of deleted resources.
+func (s *Service) DeleteAll(rtype ResourceType, confirm bool) (int, error) {
+	if !confirm {
+		return 0, ErrNotConfirmed
+	}
+	resources, err := s.doGetResources(rtype)
+	if err != nil {
+		return 0, err
+	}
+	return s.deleteListed(resources, rtype)
+}
+
+// DeleteByPrefix deletes all remote resources of type rtype whose public
+// id starts with prefix, e.g. a folder name followed by a /, like
+// DeleteAll() does. prefix can't be empty. It returns the number of
+// deleted resources.
+func (s *Service) DeleteByPrefix(prefix string, rtype ResourceType) (int, error) {
+	if prefix == "" {
+		return 0, errors.New("empty prefix, use DeleteAll() instead")
+	}
+	resources, err := s.ResourcesByPrefix(prefix, rtype, 0)
+	if err != nil {
+		return 0, err
+	}
+	return s.deleteListed(resources, rtype)
+}
+
+// DeleteOlderThan deletes all remote resources of type rtype uploaded
+// before cutoff, like DeleteAll() does. Resources listed without upload
+// time are kept. The listing is streamed, see StreamResources(). It
+// returns the number of deleted resources.
+func (s *Service) DeleteOlderThan(cutoff time.Time, rtype ResourceType) (int, error) {
+	resc, errc := s.StreamResources(context.Background(), rtype)
+	old := make([]*Resource, 0)
+	for res := range resc {
+		if !res.CreatedAt.IsZero() && res.CreatedAt.Before(cutoff) {
+			old = append(old, res)
+		}
+	}
+	if err := <-errc; err != nil {
+		return 0, err
+	}
+	return s.deleteListed(old, rtype)
+}
+
+// deleteListed deletes resources with the bulk delete API, in batches of
+// 100 public ids, skipping the ones matching the KeepFiles() pattern. It
+// returns the number of deleted resources.
+func (s *Service) deleteListed(resources []*Resource, rtype ResourceType) (int, error) {
+	ids := make([]string, 0, len(resources))
+	for _, r := range resources {
+		if s.keepFilesPattern != nil && s.keepFilesPattern.MatchString(r.PublicId) {
+			continue
+		}
+		ids = append(ids, r.PublicId)
+	}
+	deleted := 0
+	for len(ids) > 0 {
+		n := deleteBatchSize
+		if len(ids) < n {
+			n = len(ids)
+		}
+		c, err := s.deleteResources(ids[:n], rtype)
+		deleted += c
+		if err != nil {
+			return deleted, err
+		}
+		ids = ids[n:]
+	}
+	return deleted, nil
+}
+
+// deleteResources deletes a batch of resources with a single admin API
+// call. It returns the number of deleted resources.
+func (s *Service) deleteResources(publicIds []string, rtype ResourceType) (int, error) {
+	if s.simulate {
+		for _, id := range publicIds {
+			s.simulated = append(s.simulated, "delete "+id)
+		}
+		return len(publicIds), nil
+	}
+	path := resourcesPath(rtype)
+	qs := url.Values{"public_ids[]": publicIds}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s%s/upload?%s", s.adminURI, path, qs.Encode()), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := s.do(opDelete, req)
+	if err != nil {
+		return 0, err
+	}
+	m, err := handleHttpResponse(resp)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	if results, ok := m["deleted"].(map[string]interface{}); ok {
+		for _, status := range results {
+			if status == "deleted" {
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// DeleteDerivedByTransformation deletes all derived resources of type
+// rtype generated with transformation t. Original resources are kept.
+func (s *Service) DeleteDerivedByTransformation(t Transformation, rtype ResourceType) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	tr := t.Encode()
+	if tr == "" {
+		return errors.New("empty transformation")
+	}
+	if s.simulate {
+		s.simulated = append(s.simulated, "delete derived "+tr)
+		return nil
+	}
 	qs := url.Values{
-		"max_results": []string{strconv.FormatInt(maxResults, 10)},
+		"all":             []string{"true"},
+		"keep_original":   []string{"true"},
+		"transformations": []string{tr},
+	}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s%s/upload?%s", s.adminURI, resourcesPath(rtype), qs.Encode()), nil)
+	if err != nil {
+		return err
 	}
-	path := pathListAllImages
-	if rtype == RawType {
-		path = pathListAllRaws
+	resp, err := s.do(opDelete, req)
+	if err != nil {
+		return err
 	}
+	_, err = handleHttpResponse(resp)
+	return err
+}
+
+func (s *Service) doGetResources(rtype ResourceType) ([]*Resource, error) {
+	return s.listResources(resourcesPath(rtype), url.Values{}, 0)
+}
+
+// listResources returns up to max resources (0 for all of them) listed by
+// the admin API at path, with the query string parameters qs. Pagination
+// is supported.
+func (s *Service) listResources(path string, qs url.Values, max int) ([]*Resource, error) {
 	allres := make([]*Resource, 0)
 	for {
-		resp, err := http.Get(fmt.Sprintf("%s%s?%s", s.adminURI, path, qs.Encode()))
-		if err != nil {
-			return nil, err
+		n := maxPageResults
+		if max > 0 && max-len(allres) < n {
+			n = max - len(allres)
 		}
-
-		rs := new(resourceList)
-		dec := json.NewDecoder(resp.Body)
-		if err := dec.Decode(rs); err != nil {
+		qs.Set("max_results", strconv.Itoa(n))
+		rs, err := s.listPage(context.Background(), path, qs)
+		if err != nil {
 			return nil, err
 		}
 		for _, res := range rs.Resources {
 			allres = append(allres, res)
 		}
-		if rs.NextCursor > 0 {
-			qs.Set("next_cursor", strconv.FormatInt(rs.NextCursor, 10))
+		if rs.NextCursor != "" && (max == 0 || len(allres) < max) {
+			qs.Set("next_cursor", rs.NextCursor)
 		} else {
 			break
 		}
@@ -117,6 +275,91 @@ func (s *Service) doGetResources(rtype ResourceType) ([]*Resource, error) {
 	return allres, nil
 }
 
+// listPage returns a single page of the resources listed by the admin
+// API at path, with the query string parameters qs.
+func (s *Service) listPage(ctx context.Context, path string, qs url.Values) (*resourceList, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s%s?%s", s.adminURI, path, qs.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(opAdmin, req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	body, err := readResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	rs := new(resourceList)
+	if err := decodeResponse(resp, body, rs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// StreamResources lists all uploaded resources of type rtype like
+// Resources(), sending them on the returned channel as pages are
+// fetched. The channel is closed once all resources are sent, on error
+// or when ctx is cancelled: the next page is not fetched then. The error
+// channel receives the error that stopped the listing, if any, and is
+// closed afterwards.
+func (s *Service) StreamResources(ctx context.Context, rtype ResourceType) (<-chan *Resource, <-chan error) {
+	return s.streamResources(ctx, rtype, url.Values{})
+}
+
+// streamResources is like StreamResources() with the additional query
+// string parameters qs.
+func (s *Service) streamResources(ctx context.Context, rtype ResourceType, qs url.Values) (<-chan *Resource, <-chan error) {
+	out := make(chan *Resource)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(out)
+		qs.Set("max_results", strconv.Itoa(maxPageResults))
+		for {
+			if err := ctx.Err(); err != nil {
+				errc <- err
+				return
+			}
+			rs, err := s.listPage(ctx, resourcesPath(rtype), qs)
+			if err != nil {
+				errc <- err
+				return
+			}
+			for _, res := range rs.Resources {
+				select {
+				case out <- res:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+			if rs.NextCursor == "" {
+				return
+			}
+			qs.Set("next_cursor", rs.NextCursor)
+		}
+	}()
+	return out, errc
+}
+
+// TagSummary returns the number of uploaded resources of type rtype per
+// tag. Resources are streamed, see StreamResources(), so that only the
+// counts are kept in memory.
+func (s *Service) TagSummary(rtype ResourceType) (map[string]int, error) {
+	resc, errc := s.streamResources(context.Background(), rtype, url.Values{"tags": []string{"true"}})
+	counts := make(map[string]int)
+	for res := range resc {
+		for _, tag := range res.Tags {
+			counts[tag]++
+		}
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
 // Resources returns a list of all uploaded resources. They can be
 // images or raw files, depending on the resource type passed in rtype.
 // Cloudinary can return a limited set of results. Pagination is supported,
@@ -124,3 +367,338 @@ func (s *Service) doGetResources(rtype ResourceType) ([]*Resource, error) {
 func (s *Service) Resources(rtype ResourceType) ([]*Resource, error) {
 	return s.doGetResources(rtype)
 }
+
+// ResourcesByPrefix returns up to max resources (0 for all of them) of
+// type rtype whose public id starts with prefix, e.g. a folder name
+// followed by a /.
+func (s *Service) ResourcesByPrefix(prefix string, rtype ResourceType, max int) ([]*Resource, error) {
+	qs := url.Values{
+		"type":   []string{"upload"},
+		"prefix": []string{prefix},
+	}
+	return s.listResources(resourcesPath(rtype), qs, max)
+}
+
+// Reconcile compares the public ids of all uploaded resources of type
+// rtype with the expected ones. It returns the expected public ids with no
+// matching resource, and the public ids of resources not expected.
+func (s *Service) Reconcile(expected []string, rtype ResourceType) (missing, extra []string, err error) {
+	resources, err := s.doGetResources(rtype)
+	if err != nil {
+		return nil, nil, err
+	}
+	want := make(map[string]bool, len(expected))
+	for _, id := range expected {
+		want[id] = true
+	}
+	found := make(map[string]bool, len(resources))
+	extra = make([]string, 0)
+	for _, r := range resources {
+		found[r.PublicId] = true
+		if !want[r.PublicId] {
+			extra = append(extra, r.PublicId)
+		}
+	}
+	missing = make([]string, 0)
+	for _, id := range expected {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing, extra, nil
+}
+
+// Ping checks that the admin API is reachable with the current
+// credentials.
+func (s *Service) Ping() error {
+	resp, err := s.get(opAdmin, fmt.Sprintf("%s%s", s.adminURI, pathPing))
+	if err != nil {
+		return err
+	}
+	m, err := handleHttpResponse(resp)
+	if err != nil {
+		return err
+	}
+	if status, _ := m["status"].(string); status != "ok" {
+		return errors.New("unexpected ping status: " + status)
+	}
+	return nil
+}
+
+// GetResource returns the details of a single uploaded resource.
+func (s *Service) GetResource(publicId string, rtype ResourceType) (*Resource, error) {
+	path := resourcesPath(rtype)
+	resp, err := s.get(opAdmin, fmt.Sprintf("%s%s/upload/%s", s.adminURI, path, escapePublicId(publicId)))
+	if err != nil {
+		return nil, err
+	}
+	body, err := readResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	res := new(Resource)
+	if err := decodeResponse(resp, body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetResourceByURL returns the details of the uploaded resource the
+// delivery URL uri points to. See PublicIDAndType().
+func (s *Service) GetResourceByURL(uri string) (*Resource, error) {
+	publicId, rtype, err := s.PublicIDAndType(uri)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetResource(publicId, rtype)
+}
+
+// resourceExists tells whether a resource with the given public id has
+// been uploaded.
+func (s *Service) resourceExists(publicId string, rtype ResourceType) (bool, error) {
+	resp, err := s.get(opAdmin, fmt.Sprintf("%s%s/upload/%s", s.adminURI, resourcesPath(rtype), escapePublicId(publicId)))
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return false, nil
+	}
+	if _, err := readResponse(resp); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateAccessMode changes the access mode of uploaded resources. mode
+// must be either "public" or "authenticated".
+func (s *Service) UpdateAccessMode(publicIds []string, mode string, rtype ResourceType) error {
+	if err := validAccessMode(mode); err != nil {
+		return err
+	}
+	if s.simulate {
+		for _, id := range publicIds {
+			s.simulated = append(s.simulated, "update access mode "+id)
+		}
+		return nil
+	}
+	path := resourcesPath(rtype)
+	data := url.Values{
+		"access_mode":  []string{mode},
+		"public_ids[]": publicIds,
+	}
+	resp, err := s.postForm(opAdmin, fmt.Sprintf("%s%s/upload/update_access_mode", s.adminURI, path), data)
+	if err != nil {
+		return err
+	}
+	_, err = handleHttpResponse(resp)
+	return err
+}
+
+// Tags returns the tags assigned to resources of type rtype, up to max
+// tags (0 for all of them). Pagination is supported.
+func (s *Service) Tags(rtype ResourceType, max int) ([]string, error) {
+	return s.TagsByPrefix("", rtype, max)
+}
+
+// TagsByPrefix is like Tags() but only returns tags starting with prefix.
+func (s *Service) TagsByPrefix(prefix string, rtype ResourceType, max int) ([]string, error) {
+	qs := url.Values{}
+	if prefix != "" {
+		qs.Set("prefix", prefix)
+	}
+	tags := make([]string, 0)
+	for {
+		n := maxPageResults
+		if max > 0 && max-len(tags) < n {
+			n = max - len(tags)
+		}
+		qs.Set("max_results", strconv.Itoa(n))
+		resp, err := s.get(opAdmin, fmt.Sprintf("%s%s/%s?%s", s.adminURI, pathTags, typeName(rtype), qs.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		body, err := readResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		tl := new(tagList)
+		if err := decodeResponse(resp, body, tl); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tl.Tags...)
+		if tl.NextCursor == "" || (max > 0 && len(tags) >= max) {
+			break
+		}
+		qs.Set("next_cursor", tl.NextCursor)
+	}
+	return tags, nil
+}
+
+// Transformations returns the list of all transformations defined in
+// the account, named or not. Pagination is supported.
+func (s *Service) Transformations() ([]TransformationInfo, error) {
+	qs := url.Values{
+		"max_results": []string{strconv.Itoa(maxPageResults)},
+	}
+	all := make([]TransformationInfo, 0)
+	for {
+		resp, err := s.get(opAdmin, fmt.Sprintf("%s%s?%s", s.adminURI, pathTransformations, qs.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		body, err := readResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		tl := new(transformationList)
+		if err := decodeResponse(resp, body, tl); err != nil {
+			return nil, err
+		}
+		all = append(all, tl.Transformations...)
+		if tl.NextCursor == "" {
+			break
+		}
+		qs.Set("next_cursor", tl.NextCursor)
+	}
+	return all, nil
+}
+
+// UploadPresets returns the list of all upload presets defined in the
+// account. Pagination is supported.
+func (s *Service) UploadPresets() ([]Preset, error) {
+	qs := url.Values{
+		"max_results": []string{strconv.Itoa(maxPageResults)},
+	}
+	all := make([]Preset, 0)
+	for {
+		resp, err := s.get(opAdmin, fmt.Sprintf("%s%s?%s", s.adminURI, pathUploadPresets, qs.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		body, err := readResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+		pl := new(presetList)
+		if err := decodeResponse(resp, body, pl); err != nil {
+			return nil, err
+		}
+		all = append(all, pl.Presets...)
+		if pl.NextCursor == "" {
+			break
+		}
+		qs.Set("next_cursor", pl.NextCursor)
+	}
+	return all, nil
+}
+
+// DeleteUploadPreset deletes the upload preset called name.
+func (s *Service) DeleteUploadPreset(name string) error {
+	if name == "" {
+		return errors.New("empty upload preset name")
+	}
+	if s.simulate {
+		s.simulated = append(s.simulated, "delete preset "+name)
+		return nil
+	}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s%s/%s", s.adminURI, pathUploadPresets, url.PathEscape(name)), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(opDelete, req)
+	if err != nil {
+		return err
+	}
+	_, err = handleHttpResponse(resp)
+	return err
+}
+
+// CreateTransformation defines a named transformation in the account,
+// which can then be used in delivery URLs as t_<name>.
+// ErrTransformationExists is returned if the name is already taken.
+func (s *Service) CreateTransformation(name string, t Transformation) error {
+	if name == "" {
+		return errors.New("empty transformation name")
+	}
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	data := url.Values{
+		"transformation": []string{t.Encode()},
+	}
+	resp, err := s.postForm(opAdmin, fmt.Sprintf("%s%s/%s", s.adminURI, pathTransformations, url.PathEscape(name)), data)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		resp.Body.Close()
+		return ErrTransformationExists
+	}
+	_, err = handleHttpResponse(resp)
+	return err
+}
+
+// ResourcesByModeration returns up to max resources (0 for all of them)
+// of type rtype with the given moderation status in the moderation queue
+// kind (e.g. manual or webpurify). status is one of pending, approved
+// or rejected.
+func (s *Service) ResourcesByModeration(kind, status string, rtype ResourceType, max int) ([]*Resource, error) {
+	if err := validModerationStatus(status); err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("%s/moderations/%s/%s", resourcesPath(rtype), url.PathEscape(kind), status)
+	return s.listResources(path, url.Values{}, max)
+}
+
+func validModerationStatus(status string) error {
+	switch status {
+	case "pending", "approved", "rejected":
+		return nil
+	}
+	return errors.New("invalid moderation status: " + status)
+}
+
+// UpdateModerationStatus approves or rejects a resource waiting for manual
+// moderation. status is either approved or rejected.
+func (s *Service) UpdateModerationStatus(publicId string, rtype ResourceType, status string) error {
+	if status != "approved" && status != "rejected" {
+		return errors.New("invalid moderation status update: " + status)
+	}
+	return s.updateResource(publicId, rtype, url.Values{
+		"moderation_status": []string{status},
+	})
+}
+
+// UpdateMetadata sets the structured metadata fields of the resources
+// designed by publicIds. Fields are identified by their external id. A
+// failed update does not stop the others: all errors are returned as a
+// BatchError.
+func (s *Service) UpdateMetadata(publicIds []string, fields map[string]string, rtype ResourceType) error {
+	data := url.Values{"metadata": []string{encodeMetadata(fields)}}
+	errs := make(BatchError)
+	for _, id := range publicIds {
+		if err := s.updateResource(id, rtype, data); err != nil {
+			errs[id] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// updateResource calls the admin API to update a resource's properties,
+// given as form values in data.
+func (s *Service) updateResource(publicId string, rtype ResourceType, data url.Values) error {
+	if s.simulate {
+		s.simulated = append(s.simulated, "update "+publicId)
+		return nil
+	}
+	resp, err := s.postForm(opAdmin, fmt.Sprintf("%s%s/upload/%s", s.adminURI, resourcesPath(rtype), escapePublicId(publicId)), data)
+	if err != nil {
+		return err
+	}
+	_, err = handleHttpResponse(resp)
+	return err
+}
@@ -0,0 +1,710 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package cloudinary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeleteDerivedByTransformation(t *testing.T) {
+	form := url.Values{}
+	var path string
+	server := httptest.NewServer(recordPath(&path, formHandler(form, `{"deleted":{}}`)))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteDerivedByTransformation(Transformation{}, ImageType); err == nil {
+		t.Error("should fail on empty transformation")
+	}
+	if err := s.DeleteDerivedByTransformation(Transformation{Crop: "fill", Width: 300}, ImageType); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/resources/image/upload" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if form.Get("transformations") != "c_fill,w_300" {
+		t.Errorf("wrong transformations field. Expect %s, got %s", "c_fill,w_300", form.Get("transformations"))
+	}
+	if form.Get("keep_original") != "true" {
+		t.Errorf("wrong keep_original field. Expect %s, got %s", "true", form.Get("keep_original"))
+	}
+}
+
+func TestResourcesByPrefix(t *testing.T) {
+	ids := []string{"avatars/1", "banners/1", "avatars/2", "avatars/3"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		if qs.Get("type") != "upload" {
+			t.Errorf("wrong type parameter. Expect %s, got %s", "upload", qs.Get("type"))
+		}
+		// Two resources per page, the cursor being the index of the next one
+		start, _ := strconv.Atoi(qs.Get("next_cursor"))
+		res := make([]string, 0)
+		cursor := ""
+		for k := start; k < len(ids); k++ {
+			if k == start+2 {
+				cursor = fmt.Sprintf(`,"next_cursor":"%d"`, k)
+				break
+			}
+			if strings.HasPrefix(ids[k], qs.Get("prefix")) {
+				res = append(res, fmt.Sprintf(`{"public_id":"%s"}`, ids[k]))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"resources":[%s]%s}`, strings.Join(res, ","), cursor)
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.ResourcesByPrefix("avatars/", ImageType, 0)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("wrong number of resources. Expect %d, got %d", 3, len(res))
+	}
+	for _, r := range res {
+		if !strings.HasPrefix(r.PublicId, "avatars/") {
+			t.Errorf("resource %s doesn't match prefix", r.PublicId)
+		}
+	}
+}
+
+func TestStreamResources(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Endless listing, two resources per page
+		n := atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"resources":[{"public_id":"%d-a"},{"public_id":"%d-b"}],"next_cursor":"%d"}`, n, n, n)
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	resc, errc := s.StreamResources(ctx, ImageType)
+	for _, id := range []string{"1-a", "1-b", "2-a"} {
+		if res := <-resc; res.PublicId != id {
+			t.Errorf("wrong public id. Expect %s, got %s", id, res.PublicId)
+		}
+	}
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		for range resc {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("resource channel not closed after cancellation")
+	}
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("wrong error. Expect %v, got %v", context.Canceled, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("wrong number of requests. Expect %d, got %d", 2, n)
+	}
+}
+
+func TestTagSummary(t *testing.T) {
+	pages := []string{
+		`{"resources":[{"public_id":"a","tags":["red","shoe"]},{"public_id":"b","tags":["red"]}],"next_cursor":"1"}`,
+		`{"resources":[{"public_id":"c","tags":["shoe","sale","red"]},{"public_id":"d"}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		qs := r.URL.Query()
+		if qs.Get("tags") != "true" {
+			t.Errorf("wrong tags parameter. Expect %s, got %s", "true", qs.Get("tags"))
+		}
+		page, _ := strconv.Atoi(qs.Get("next_cursor"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, pages[page])
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := s.TagSummary(ImageType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	expected := map[string]int{"red": 3, "shoe": 2, "sale": 1}
+	if len(counts) != len(expected) {
+		t.Errorf("wrong number of tags. Expect %d, got %d", len(expected), len(counts))
+	}
+	for tag, n := range expected {
+		if counts[tag] != n {
+			t.Errorf("wrong count for tag %s. Expect %d, got %d", tag, n, counts[tag])
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	server := mockFormServer(url.Values{}, `{"resources":[{"public_id":"a"},{"public_id":"b"},{"public_id":"d"}]}`)
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	missing, extra, err := s.Reconcile([]string{"a", "b", "c"}, ImageType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if len(missing) != 1 || missing[0] != "c" {
+		t.Errorf("wrong missing resources. Expect %v, got %v", []string{"c"}, missing)
+	}
+	if len(extra) != 1 || extra[0] != "d" {
+		t.Errorf("wrong extra resources. Expect %v, got %v", []string{"d"}, extra)
+	}
+}
+
+func TestUpdateAccessMode(t *testing.T) {
+	form := url.Values{}
+	var path string
+	server := httptest.NewServer(recordPath(&path, formHandler(form, `{"updated":[{"public_id":"a","access_mode":"public"}],"failed":[]}`)))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateAccessMode([]string{"a", "b"}, "private", ImageType); err == nil {
+		t.Error("should fail on invalid access mode")
+	}
+	if err := s.UpdateAccessMode([]string{"a", "b"}, AccessModePublic, RawType); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/resources/raw/upload/update_access_mode" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if form.Get("access_mode") != AccessModePublic {
+		t.Errorf("wrong access_mode field. Expect %s, got %s", AccessModePublic, form.Get("access_mode"))
+	}
+	if ids := form["public_ids[]"]; len(ids) != 2 || ids[0] != "a" || ids[1] != "b" {
+		t.Errorf("wrong public_ids[] field: %v", ids)
+	}
+
+	// Nothing is sent in simulate mode
+	path = ""
+	s.Simulate(true)
+	if err := s.UpdateAccessMode([]string{"a", "b"}, AccessModeAuthenticated, ImageType); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "" {
+		t.Errorf("no request expected in simulate mode, got %s", path)
+	}
+	if a := s.SimulatedActions(); len(a) != 2 || a[0] != "update access mode a" {
+		t.Errorf("wrong simulated actions: %v", a)
+	}
+}
+
+func TestRawResources(t *testing.T) {
+	ids := []string{"js/app.js", "js/vendor.js", "css/site.css", "js/keep.js"}
+	paths := make([]string, 0)
+	deleted := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			res := make([]string, 0)
+			for _, id := range ids {
+				if strings.HasPrefix(id, r.URL.Query().Get("prefix")) {
+					res = append(res, fmt.Sprintf(`{"public_id":"%s","resource_type":"raw"}`, id))
+				}
+			}
+			fmt.Fprintf(w, `{"resources":[%s]}`, strings.Join(res, ","))
+		case "DELETE":
+			status := make(map[string]string)
+			for _, id := range r.URL.Query()["public_ids[]"] {
+				deleted = append(deleted, id)
+				status[id] = "deleted"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"deleted": status})
+		}
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.Resources(RawType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if len(res) != len(ids) || paths[0] != "GET /resources/raw" {
+		t.Errorf("wrong raw listing: %d resources from %s", len(res), paths[0])
+	}
+	if _, err := s.DeleteByPrefix("", RawType); err == nil {
+		t.Error("should fail on empty prefix")
+	}
+	if err := s.KeepFiles("keep"); err != nil {
+		t.Fatal(err)
+	}
+	paths = paths[:0]
+	n, err := s.DeleteByPrefix("js/", RawType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if n != 2 || strings.Join(deleted, " ") != "js/app.js js/vendor.js" {
+		t.Errorf("wrong deleted resources. Expect [js/app.js js/vendor.js], got %d %v", n, deleted)
+	}
+	expected := "GET /resources/raw DELETE /resources/raw/upload"
+	if got := strings.Join(paths, " "); got != expected {
+		t.Errorf("wrong requests. Expect %s, got %s", expected, got)
+	}
+}
+
+func TestDeleteOlderThan(t *testing.T) {
+	pages := []string{
+		`{"resources":[{"public_id":"old1","created_at":"2013-05-24T18:02:11Z"},{"public_id":"new1","created_at":"2014-02-01T08:00:00Z"}],"next_cursor":"1"}`,
+		`{"resources":[{"public_id":"old2","created_at":"2013-12-31T23:59:59Z"},{"public_id":"unknown"},{"public_id":"new2","created_at":"2014-01-01T00:00:00Z"}]}`,
+	}
+	deleted := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			page, _ := strconv.Atoi(r.URL.Query().Get("next_cursor"))
+			fmt.Fprint(w, pages[page])
+		case "DELETE":
+			status := make(map[string]string)
+			for _, id := range r.URL.Query()["public_ids[]"] {
+				deleted = append(deleted, id)
+				status[id] = "deleted"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"deleted": status})
+		}
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.DeleteOlderThan(time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC), ImageType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if n != 2 || strings.Join(deleted, " ") != "old1 old2" {
+		t.Errorf("wrong deleted resources. Expect [old1 old2], got %d %v", n, deleted)
+	}
+}
+
+func TestDeleteAll(t *testing.T) {
+	s := cloudinaryService()
+	if _, err := s.DeleteAll(ImageType, false); err != ErrNotConfirmed {
+		t.Errorf("wrong error without confirmation. Expect %v, got %v", ErrNotConfirmed, err)
+	}
+
+	// 150 resources, listed in two pages
+	batches := make([]int, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case "GET":
+			first, cursor := 0, `"next_cursor":"page2",`
+			if r.URL.Query().Get("next_cursor") == "page2" {
+				first, cursor = 100, ""
+			}
+			res := make([]string, 0)
+			for k := first; k < first+100 && k < 150; k++ {
+				res = append(res, fmt.Sprintf(`{"public_id":"img%d"}`, k))
+			}
+			fmt.Fprintf(w, `{%s"resources":[%s]}`, cursor, strings.Join(res, ","))
+		case "DELETE":
+			ids := r.URL.Query()["public_ids[]"]
+			batches = append(batches, len(ids))
+			deleted := make(map[string]string)
+			for _, id := range ids {
+				deleted[id] = "deleted"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"deleted": deleted})
+		}
+	}))
+	defer server.Close()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.DeleteAll(ImageType, true)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if n != 150 {
+		t.Errorf("wrong number of deleted resources. Expect %d, got %d", 150, n)
+	}
+	if len(batches) != 2 || batches[0] != 100 || batches[1] != 50 {
+		t.Errorf("wrong delete batches. Expect [100 50], got %v", batches)
+	}
+}
+
+func TestTags(t *testing.T) {
+	var path string
+	queries := make([]url.Values, 0)
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("next_cursor") == "" {
+			fmt.Fprintln(w, `{"tags":["animal","blue"],"next_cursor":"c2"}`)
+		} else {
+			fmt.Fprintln(w, `{"tags":["bluish"]}`)
+		}
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := s.TagsByPrefix("b", RawType, 0)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if strings.Join(tags, ",") != "animal,blue,bluish" {
+		t.Errorf("wrong merged tags list: %v", tags)
+	}
+	if path != "/tags/raw" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if len(queries) != 2 || queries[0].Get("prefix") != "b" || queries[1].Get("next_cursor") != "c2" {
+		t.Errorf("wrong pagination queries: %v", queries)
+	}
+
+	// Stops paginating once max tags are fetched
+	queries = queries[:0]
+	if tags, err = s.Tags(ImageType, 2); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if len(tags) != 2 || len(queries) != 1 || queries[0].Get("max_results") != "2" {
+		t.Errorf("wrong tags list with max results: %v", tags)
+	}
+}
+
+func TestTransformations(t *testing.T) {
+	var path string
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("next_cursor") == "" {
+			fmt.Fprintln(w, `{"transformations":[{"name":"t_thumb","allowed_for_strict":true,"used":true},{"name":"c_fill,w_300","allowed_for_strict":false,"used":true}],"next_cursor":"c2"}`)
+		} else {
+			fmt.Fprintln(w, `{"transformations":[{"name":"t_banner","allowed_for_strict":false,"used":false}]}`)
+		}
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := s.Transformations()
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/transformations" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	expected := []TransformationInfo{
+		{"t_thumb", true, true},
+		{"c_fill,w_300", false, true},
+		{"t_banner", false, false},
+	}
+	if len(ts) != len(expected) {
+		t.Fatalf("wrong number of transformations. Expect %d, got %d", len(expected), len(ts))
+	}
+	for k, e := range expected {
+		if ts[k] != e {
+			t.Errorf("wrong transformation. Expect %v, got %v", e, ts[k])
+		}
+	}
+}
+
+func TestUploadPresets(t *testing.T) {
+	var path string
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("next_cursor") == "" {
+			fmt.Fprintln(w, `{"presets":[{"name":"avatars","unsigned":true,"settings":{"folder":"avatars","tags":"user"}}],"next_cursor":"c2"}`)
+		} else {
+			fmt.Fprintln(w, `{"presets":[{"name":"docs","unsigned":false,"settings":{}}]}`)
+		}
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	presets, err := s.UploadPresets()
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/upload_presets" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if len(presets) != 2 {
+		t.Fatalf("wrong number of presets. Expect %d, got %d", 2, len(presets))
+	}
+	if p := presets[0]; p.Name != "avatars" || !p.Unsigned || p.Settings["folder"] != "avatars" {
+		t.Errorf("wrong decoded preset: %+v", p)
+	}
+	if p := presets[1]; p.Name != "docs" || p.Unsigned {
+		t.Errorf("wrong decoded preset: %+v", p)
+	}
+}
+
+func TestDeleteUploadPreset(t *testing.T) {
+	var path, method string
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintln(w, `{"message":"deleted"}`)
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteUploadPreset("avatars"); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if method != "DELETE" || path != "/upload_presets/avatars" {
+		t.Errorf("wrong request: %s %s", method, path)
+	}
+}
+
+func TestCreateTransformation(t *testing.T) {
+	form := url.Values{}
+	var path string
+	server := httptest.NewServer(recordPath(&path, formHandler(form, `{"message":"created"}`)))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateTransformation("thumb", Transformation{Crop: "fill", Width: 150, Height: 150}); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/transformations/thumb" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if tr := form.Get("transformation"); tr != "c_fill,w_150,h_150" {
+		t.Errorf("wrong transformation field. Expect %s, got %s", "c_fill,w_150,h_150", tr)
+	}
+
+	conflict := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprintln(w, `{"error":{"message":"Transformation thumb already exists"}}`)
+	}))
+	defer conflict.Close()
+	if err := s.AdminURI(conflict.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateTransformation("thumb", Transformation{Width: 150}); err != ErrTransformationExists {
+		t.Errorf("wrong error for existing name. Expect %v, got %v", ErrTransformationExists, err)
+	}
+}
+
+func TestResourcesByModeration(t *testing.T) {
+	var path string
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("next_cursor") == "" {
+			fmt.Fprintln(w, `{"resources":[{"public_id":"a","resource_type":"image"}],"next_cursor":"c2"}`)
+		} else {
+			fmt.Fprintln(w, `{"resources":[{"public_id":"b","resource_type":"image"}]}`)
+		}
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResourcesByModeration("manual", "unknown", ImageType, 0); err == nil {
+		t.Error("should fail on invalid moderation status")
+	}
+	res, err := s.ResourcesByModeration("manual", "pending", ImageType, 0)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/resources/image/moderations/manual/pending" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if len(res) != 2 || res[0].PublicId != "a" || res[1].PublicId != "b" {
+		t.Errorf("wrong pending resources: %v", res)
+	}
+	if res, err = s.ResourcesByModeration("manual", "pending", ImageType, 1); err != nil || len(res) != 1 {
+		t.Errorf("expected 1 resource with max results, got %d (%v)", len(res), err)
+	}
+}
+
+func TestUpdateModerationStatus(t *testing.T) {
+	form := url.Values{}
+	var path string
+	server := httptest.NewServer(recordPath(&path, formHandler(form, `{"public_id":"avatars/42","moderation_status":"approved"}`)))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateModerationStatus("avatars/42", ImageType, "pending"); err == nil {
+		t.Error("should fail on invalid moderation status")
+	}
+	if err := s.UpdateModerationStatus("avatars/42", ImageType, "approved"); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/resources/image/upload/avatars/42" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if form.Get("moderation_status") != "approved" {
+		t.Errorf("wrong moderation_status field. Expect %s, got %s", "approved", form.Get("moderation_status"))
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	form := url.Values{}
+	var path string
+	server := httptest.NewServer(recordPath(&path, formHandler(form, `{"public_id":"avatars/42","metadata":{"color":"blue","size":"xl"}}`)))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{"size": "xl", "color": "blue"}
+	if err := s.UpdateMetadata([]string{"avatars/42"}, fields, ImageType); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/resources/image/upload/avatars/42" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if form.Get("metadata") != "color=blue|size=xl" {
+		t.Errorf("wrong metadata field. Expect %s, got %s", "color=blue|size=xl", form.Get("metadata"))
+	}
+	res, err := s.GetResource("avatars/42", ImageType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Metadata["color"] != "blue" {
+		t.Errorf("wrong color metadata. Expect %s, got %v", "blue", res.Metadata["color"])
+	}
+}
+
+func TestEncodeMetadata(t *testing.T) {
+	fields := []struct {
+		fields   map[string]string
+		expected string
+	}{
+		{map[string]string{}, ""},
+		{map[string]string{"b": "2", "a": "1"}, "a=1|b=2"},
+		{map[string]string{"eq": "x=y", "pipe": "a|b"}, `eq=x\=y|pipe=a\|b`},
+	}
+	for _, f := range fields {
+		if got := encodeMetadata(f.fields); got != f.expected {
+			t.Errorf("wrong metadata encoding. Expect %s, got %s", f.expected, got)
+		}
+	}
+}
+
+func TestGetResource(t *testing.T) {
+	var path, rawPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, rawPath = r.URL.Path, r.URL.EscapedPath()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"public_id":"avatars/42","format":"png","resource_type":"image"}`)
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	ids := []struct {
+		publicId string
+		escaped  string
+	}{
+		{"avatars/42", "avatars/42"},
+		{"what?", "what%3F"},
+		{"tag#1", "tag%231"},
+		{"100%", "100%25"},
+		{"my photos/a b", "my%20photos/a%20b"},
+	}
+	for _, id := range ids {
+		if _, err := s.GetResource(id.publicId, ImageType); err != nil {
+			t.Fatal("expected no error to occur", err)
+		}
+		if path != "/resources/image/upload/"+id.publicId {
+			t.Errorf("wrong request path. Expect %s, got %s", "/resources/image/upload/"+id.publicId, path)
+		}
+		if rawPath != "/resources/image/upload/"+id.escaped {
+			t.Errorf("wrong escaped request path. Expect %s, got %s", "/resources/image/upload/"+id.escaped, rawPath)
+		}
+	}
+}
+
+func TestGetResourceByURL(t *testing.T) {
+	var path string
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"public_id":"avatars/42","format":"png","resource_type":"image"}`)
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.AdminURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	res, err := s.GetResourceByURL(baseResourceUrl + "/cloudname/image/upload/c_fill,w_100,h_100/v1371995958/avatars/42.png")
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/resources/image/upload/avatars/42" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if res.PublicId != "avatars/42" {
+		t.Errorf("wrong public id. Expect %s, got %s", "avatars/42", res.PublicId)
+	}
+	// Escaped delivery URLs
+	for _, id := range []string{"what?", "tag#1", "100%", "my photos/a b"} {
+		if _, err := s.GetResourceByURL(s.Url(id, ImageType)); err != nil {
+			t.Fatal("expected no error to occur", err)
+		}
+		if path != "/resources/image/upload/"+id {
+			t.Errorf("wrong request path. Expect %s, got %s", "/resources/image/upload/"+id, path)
+		}
+	}
+	if _, err := s.GetResourceByURL(baseResourceUrl + "/cloudname/image/upload"); err != ErrUnexpectedURLPathFormat {
+		t.Errorf("wrong error. Expect %v, got %v", ErrUnexpectedURLPathFormat, err)
+	}
+}
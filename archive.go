@@ -0,0 +1,113 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudinary
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// ArchiveModeCreate stores the generated archive as a raw resource.
+	ArchiveModeCreate = "create"
+	// ArchiveModeDownload delivers the generated archive on the fly,
+	// without storing it.
+	ArchiveModeDownload = "download"
+)
+
+// archiveParams returns the signed parameters generating a zip archive
+// of the resources tagged with any of tags. Array values such as tags
+// are signed as comma separated values.
+func (s *Service) archiveParams(tags []string, mode string) (map[string]string, error) {
+	if len(tags) == 0 {
+		return nil, errors.New("no tags to archive")
+	}
+	params := map[string]string{
+		"mode":          mode,
+		"tags":          strings.Join(tags, ","),
+		"target_format": "zip",
+		"timestamp":     s.timestamp(),
+	}
+	params["signature"] = s.sign(params)
+	params["api_key"] = s.apiKey
+	return params, nil
+}
+
+// archiveValues converts archive parameters to form values, tags being
+// sent as an array.
+func archiveValues(params map[string]string, tags []string) url.Values {
+	data := url.Values{}
+	for k, v := range params {
+		if k != "tags" {
+			data.Set(k, v)
+		}
+	}
+	data["tags[]"] = tags
+	return data
+}
+
+// GenerateArchive creates a zip archive of all resources of type rtype
+// tagged with any of tags (ArchiveModeCreate). The archive is stored as a
+// raw resource, whose URL and size in bytes are returned. See
+// DownloadArchive() to get the archive without storing it.
+func (s *Service) GenerateArchive(tags []string, rtype ResourceType) (*Resource, error) {
+	params, err := s.archiveParams(tags, ArchiveModeCreate)
+	if err != nil {
+		return nil, err
+	}
+	if s.simulate {
+		s.simulated = append(s.simulated, "archive "+params["tags"])
+		return &Resource{ResourceType: rawType}, nil
+	}
+	resp, err := s.postForm(opCreate, s.endpoint("generate_archive", rtype), archiveValues(params, tags))
+	if err != nil {
+		return nil, err
+	}
+	body, err := readResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	res := new(Resource)
+	if err := decodeResponse(resp, body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ArchiveDownloadUrl returns a signed URL delivering on the fly a zip
+// archive of all resources of type rtype tagged with any of tags. It can
+// be handed to a browser as is; no API call is made. The signature holds
+// a timestamp, so the URL expires after about an hour. It returns an
+// empty string if tags is empty.
+func (s *Service) ArchiveDownloadUrl(tags []string, rtype ResourceType) string {
+	params, err := s.archiveParams(tags, ArchiveModeDownload)
+	if err != nil {
+		return ""
+	}
+	return s.endpoint("generate_archive", rtype) + "?" + archiveValues(params, tags).Encode()
+}
+
+// DownloadArchive generates on the fly a zip archive of all resources of
+// type rtype tagged with any of tags (ArchiveModeDownload) and returns its
+// content. Nothing is stored. The caller must close the returned body.
+func (s *Service) DownloadArchive(tags []string, rtype ResourceType) (io.ReadCloser, error) {
+	u := s.ArchiveDownloadUrl(tags, rtype)
+	if u == "" {
+		return nil, errors.New("no tags to archive")
+	}
+	resp, err := s.get(opDownload, u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_, err := readResponse(resp)
+		return nil, err
+	}
+	return resp.Body, nil
+}
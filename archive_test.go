@@ -0,0 +1,108 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package cloudinary
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGenerateArchive(t *testing.T) {
+	form := url.Values{}
+	var path string
+	server := httptest.NewServer(recordPath(&path, formHandler(form, `{"public_id":"album.zip","resource_type":"raw","bytes":40960,
+		"url":"http://res.cloudinary.com/cloudname/raw/upload/v1369431906/album.zip",
+		"secure_url":"https://res.cloudinary.com/cloudname/raw/upload/v1369431906/album.zip"}`)))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.UploadURI(server.URL + "/image/upload/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GenerateArchive(nil, ImageType); err == nil {
+		t.Error("should fail without tags")
+	}
+	res, err := s.GenerateArchive([]string{"holidays", "beach"}, ImageType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if path != "/image/generate_archive/" {
+		t.Errorf("wrong request path: %s", path)
+	}
+	if tags := form["tags[]"]; len(tags) != 2 || tags[0] != "holidays" || tags[1] != "beach" {
+		t.Errorf("wrong tags field: %v", tags)
+	}
+	if form.Get("target_format") != "zip" || form.Get("mode") != ArchiveModeCreate {
+		t.Errorf("wrong archive fields: %v", form)
+	}
+	params := map[string]string{
+		"mode":          form.Get("mode"),
+		"tags":          "holidays,beach",
+		"target_format": form.Get("target_format"),
+		"timestamp":     form.Get("timestamp"),
+	}
+	if sig := s.sign(params); form.Get("signature") != sig {
+		t.Errorf("wrong signature. Expect %s, got %s", sig, form.Get("signature"))
+	}
+	if res.Size != 40960 || res.Url != "http://res.cloudinary.com/cloudname/raw/upload/v1369431906/album.zip" {
+		t.Errorf("wrong archive resource: %+v", *res)
+	}
+}
+
+func TestDownloadArchive(t *testing.T) {
+	var query url.Values
+	var method, path string
+	server := httptest.NewServer(recordPath(&path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, query = r.Method, r.URL.Query()
+		w.Header().Set("Content-Type", "application/zip")
+		fmt.Fprint(w, "PK zip content")
+	})))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.UploadURI(server.URL + "/image/upload/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DownloadArchive(nil, ImageType); err == nil {
+		t.Error("should fail without tags")
+	}
+	body, err := s.DownloadArchive([]string{"holidays", "beach"}, ImageType)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "PK zip content" {
+		t.Errorf("wrong archive content. Expect %s, got %s", "PK zip content", data)
+	}
+	if method != "GET" || path != "/image/generate_archive/" || query.Get("mode") != ArchiveModeDownload {
+		t.Errorf("wrong archive request: %s %s %v", method, path, query)
+	}
+	if tags := query["tags[]"]; len(tags) != 2 || tags[0] != "holidays" || tags[1] != "beach" {
+		t.Errorf("wrong tags in download request: %v", tags)
+	}
+}
+
+func TestArchiveDownloadUrl(t *testing.T) {
+	s := cloudinaryService()
+	s.SetClock(func() time.Time { return time.Unix(1315060510, 0) })
+	if u := s.ArchiveDownloadUrl(nil, ImageType); u != "" {
+		t.Errorf("expected no URL without tags, got %s", u)
+	}
+	expected := baseUploadUrl + "/cloudname/image/generate_archive/?api_key=login&mode=download" +
+		"&signature=7613ab1d437c4b448d06608e6107d193d114cd03" +
+		"&tags%5B%5D=holidays&tags%5B%5D=beach&target_format=zip&timestamp=1315060510"
+	if u := s.ArchiveDownloadUrl([]string{"holidays", "beach"}, ImageType); u != expected {
+		t.Errorf("wrong archive download URL. Expect %s, got %s", expected, u)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudinary
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+)
+
+// ErrUnknownBatch is raised by CancelBatch() when no running batch matches
+// the token.
+var ErrUnknownBatch = errors.New("unknown or finished upload batch")
+
+// ErrNotDialed is raised by UploadBatch() on a service not created with
+// Dial(), which has nowhere to register the batch.
+var ErrNotDialed = errors.New("service not created with Dial()")
+
+// batches holds the cancel functions of the running upload batches, by
+// token. It is created by Dial() and shared by clones.
+type batches struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// UploadBatch uploads the files at paths in the background, one after the
+// other, like Upload() does. It returns a token identifying the batch for
+// CancelBatch() and a channel receiving the error that stopped the batch,
+// or nil, once it is over.
+func (s *Service) UploadBatch(paths []string, prepend string, rtype ResourceType) (string, <-chan error) {
+	done := make(chan error, 1)
+	if s.batches == nil {
+		done <- ErrNotDialed
+		close(done)
+		return "", done
+	}
+	token, err := batchToken()
+	if err != nil {
+		done <- err
+		close(done)
+		return "", done
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.batches.Lock()
+	s.batches.cancels[token] = cancel
+	s.batches.Unlock()
+
+	c := s.Clone()
+	c.ctx = ctx
+	go func() {
+		defer close(done)
+		defer func() {
+			s.batches.Lock()
+			delete(s.batches.cancels, token)
+			s.batches.Unlock()
+			cancel()
+		}()
+		for _, path := range paths {
+			if err := ctx.Err(); err != nil {
+				done <- err
+				return
+			}
+			if _, err := c.Upload(path, nil, prepend, false, rtype); err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	return token, done
+}
+
+// CancelBatch cancels the upload batch started with UploadBatch() designed
+// by token. Cloudinary has no API to cancel uploads: the cancellation is
+// done client-side, aborting the request in flight and skipping the
+// remaining files. Files already uploaded are kept. The batch then reports
+// context.Canceled.
+func (s *Service) CancelBatch(token string) error {
+	if s.batches == nil {
+		return ErrUnknownBatch
+	}
+	s.batches.Lock()
+	cancel, ok := s.batches.cancels[token]
+	s.batches.Unlock()
+	if !ok {
+		return ErrUnknownBatch
+	}
+	cancel()
+	return nil
+}
+
+// batchToken returns a new random batch token.
+func batchToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package cloudinary
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCancelBatch(t *testing.T) {
+	var requests int32
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&requests, 1)
+		started <- struct{}{}
+		// Never answers: wait for the client to give up
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cloudinary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := make([]string, 0)
+	for k := 0; k < 3; k++ {
+		path := filepath.Join(dir, fmt.Sprintf("%d.png", k))
+		if err := ioutil.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	s := cloudinaryService()
+	if err := s.UploadURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelBatch("unknown"); err != ErrUnknownBatch {
+		t.Errorf("wrong error for unknown batch. Expect %v, got %v", ErrUnknownBatch, err)
+	}
+	token, done := s.UploadBatch(paths, "", ImageType)
+	<-started
+	if err := s.CancelBatch(token); err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("wrong batch error. Expect %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not stopped after cancellation")
+	}
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("wrong number of requests. Expect %d, got %d", 1, n)
+	}
+	if err := s.CancelBatch(token); err != ErrUnknownBatch {
+		t.Errorf("wrong error for finished batch. Expect %v, got %v", ErrUnknownBatch, err)
+	}
+}
+
+func TestConcurrentBatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		fmt.Fprint(w, `{"public_id":"test","version":1369431906,"format":"png","resource_type":"image"}`)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "cloudinary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logo.png")
+	if err := ioutil.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, done := new(Service).UploadBatch([]string{path}, "", ImageType); <-done != ErrNotDialed {
+		t.Errorf("wrong error without Dial(). Expect %v", ErrNotDialed)
+	}
+
+	s := cloudinaryService()
+	if err := s.UploadURI(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	// Batches started at the same time from the service and its clones
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for k := 0; k < 20; k++ {
+		c := s
+		if k%2 == 1 {
+			c = s.Clone()
+		}
+		wg.Add(1)
+		go func(c *Service) {
+			defer wg.Done()
+			_, done := c.UploadBatch([]string{path}, "", ImageType)
+			// Closed once the batch is unregistered
+			for err := range done {
+				errs <- err
+			}
+		}(c)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error("expected no error to occur", err)
+		}
+	}
+	s.batches.Lock()
+	defer s.batches.Unlock()
+	if n := len(s.batches.cancels); n != 0 {
+		t.Errorf("finished batches should be unregistered. Expect 0 running batches, got %d", n)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudinary
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+)
+
+// dedupCache remembers the resources uploaded during the run, by upload
+// parameters and content checksum, so that uploading the same content
+// with the same public id and options again does not send another
+// request. Least recently used entries are evicted first once the cache
+// holds more than size entries. A nil cache stores nothing.
+type dedupCache struct {
+	mu      sync.Mutex
+	size    int // Max number of entries
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type dedupEntry struct {
+	key string
+	res Resource
+}
+
+func newDedupCache(size int) *dedupCache {
+	return &dedupCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// dedupKey identifies an upload to endpoint with the given signed
+// parameters and content checksum. The timestamp and signature are left
+// out since they change with time.
+func dedupKey(endpoint string, params map[string]string, checksum string) string {
+	parts := []string{endpoint}
+	for _, k := range sortedKeys(params) {
+		if k == "timestamp" || k == "signature" {
+			continue
+		}
+		parts = append(parts, k+"="+params[k])
+	}
+	return strings.Join(append(parts, checksum), "|")
+}
+
+// get returns a copy of the resource stored for key, if any.
+func (c *dedupCache) get(key string) (*Resource, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	res := e.Value.(*dedupEntry).res
+	return &res, true
+}
+
+func (c *dedupCache) put(key string, res *Resource) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*dedupEntry).res = *res
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&dedupEntry{key: key, res: *res})
+	c.evict()
+}
+
+// evict drops the least recently used entries above the size limit.
+// Must be called with c.mu held.
+func (c *dedupCache) evict() {
+	for c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, e.Value.(*dedupEntry).key)
+	}
+}
+
+func (c *dedupCache) setSize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	c.evict()
+}
+
+func (c *dedupCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+func (c *dedupCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
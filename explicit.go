@@ -0,0 +1,125 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudinary
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Explicit generates the eager transformations of the already uploaded
+// resource designed by publicId, e.g. to backfill a new rendition. The
+// generated versions are returned in the Eager field of the resource.
+func (s *Service) Explicit(publicId string, rtype ResourceType, eager []Transformation) (*Resource, error) {
+	if err := validateEager(eager); err != nil {
+		return nil, err
+	}
+	if s.simulate {
+		s.simulated = append(s.simulated, "explicit "+publicId)
+		return &Resource{PublicId: publicId, ResourceType: typeName(rtype)}, nil
+	}
+	return s.explicit(publicId, rtype, eager)
+}
+
+// ExplicitBatch calls Explicit() for all publicIds, using up to workers
+// concurrent requests. The resources are returned by public id. Failed
+// calls don't stop the others: their errors are returned as a BatchError,
+// along with the resources processed successfully.
+func (s *Service) ExplicitBatch(publicIds []string, rtype ResourceType, eager []Transformation, workers int) (map[string]*Resource, error) {
+	if err := validateEager(eager); err != nil {
+		return nil, err
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	results := make(map[string]*Resource, len(publicIds))
+	if s.simulate {
+		for _, id := range publicIds {
+			s.simulated = append(s.simulated, "explicit "+id)
+			results[id] = &Resource{PublicId: id, ResourceType: typeName(rtype)}
+		}
+		return results, nil
+	}
+	errs := make(BatchError)
+	ids := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for k := 0; k < workers; k++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				res, err := s.explicit(id, rtype, eager)
+				mu.Lock()
+				if err != nil {
+					errs[id] = err
+				} else {
+					results[id] = res
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range publicIds {
+		ids <- id
+	}
+	close(ids)
+	wg.Wait()
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// validateEager checks that there is at least one eager transformation
+// and that all of them are valid.
+func validateEager(eager []Transformation) error {
+	if len(eager) == 0 {
+		return errors.New("no eager transformation")
+	}
+	for _, t := range eager {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// explicit sends a signed explicit request.
+func (s *Service) explicit(publicId string, rtype ResourceType, eager []Transformation) (*Resource, error) {
+	// Eager transformations are separated by |, not chained
+	steps := make([]string, 0, len(eager))
+	for _, t := range eager {
+		steps = append(steps, t.Encode())
+	}
+	params := map[string]string{
+		"eager":     strings.Join(steps, "|"),
+		"public_id": publicId,
+		"timestamp": s.timestamp(),
+		"type":      "upload",
+	}
+	params["signature"] = s.sign(params)
+	params["api_key"] = s.apiKey
+	data := url.Values{}
+	for k, v := range params {
+		data.Set(k, v)
+	}
+	resp, err := s.postForm(opUpload, s.endpoint("explicit", rtype), data)
+	if err != nil {
+		return nil, err
+	}
+	body, err := readResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	res := new(Resource)
+	if err := decodeResponse(resp, body, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package cloudinary
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestExplicitBatch(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id := r.Form.Get("public_id")
+		mu.Lock()
+		seen[id] = r.Form.Get("eager")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if id == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"Resource not found - broken"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"public_id":"%s","eager":[{"transformation":"c_fill,w_100,h_100"}]}`, id)
+	}))
+	defer server.Close()
+
+	s := cloudinaryService()
+	if err := s.UploadURI(server.URL + "/image/upload/"); err != nil {
+		t.Fatal(err)
+	}
+	eager := []Transformation{{Crop: "fill", Width: 100, Height: 100}, {Width: 50}}
+	if _, err := s.ExplicitBatch([]string{"a"}, ImageType, nil, 2); err == nil {
+		t.Error("should fail without eager transformation")
+	}
+	ids := []string{"a", "b", "broken", "c", "d"}
+	results, err := s.ExplicitBatch(ids, ImageType, eager, 2)
+	errs, ok := err.(BatchError)
+	if !ok || len(errs) != 1 || errs["broken"] == nil {
+		t.Errorf("wrong batch error. Expect an error for broken only, got %v", err)
+	}
+	if len(seen) != len(ids) {
+		t.Errorf("wrong number of processed ids. Expect %d, got %d", len(ids), len(seen))
+	}
+	for _, id := range ids {
+		if seen[id] != "c_fill,w_100,h_100|w_50" {
+			t.Errorf("wrong eager field for %s. Expect %s, got %s", id, "c_fill,w_100,h_100|w_50", seen[id])
+		}
+		if id == "broken" {
+			continue
+		}
+		if res := results[id]; res == nil || res.PublicId != id || len(res.Eager) != 1 {
+			t.Errorf("wrong result for %s: %+v", id, res)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudinary
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AccessModePublic        = "public"
+	AccessModeAuthenticated = "authenticated"
+)
+
+const (
+	AccessTypeAnonymous = "anonymous"
+	AccessTypeToken     = "token"
+)
+
+// AccessControl restricts the access to a resource. Anonymous access can
+// be limited to a time window between Start and End, zero values leaving
+// it open. Token access requires a signed token on delivery.
+type AccessControl struct {
+	AccessType string // anonymous or token
+	Start      time.Time
+	End        time.Time
+}
+
+// accessControlLayouts lists the time layouts used by Cloudinary for the
+// start and end of access control windows.
+var accessControlLayouts = []string{time.RFC3339, "2006-01-02T15:04Z07:00"}
+
+type accessControlJSON struct {
+	AccessType string `json:"access_type"`
+	Start      string `json:"start,omitempty"`
+	End        string `json:"end,omitempty"`
+}
+
+// MarshalJSON encodes the access control as expected by the upload API,
+// e.g. {"access_type":"anonymous","start":"2017-12-15T12:00:00Z"}.
+func (a AccessControl) MarshalJSON() ([]byte, error) {
+	v := accessControlJSON{AccessType: a.AccessType}
+	if !a.Start.IsZero() {
+		v.Start = a.Start.UTC().Format(time.RFC3339)
+	}
+	if !a.End.IsZero() {
+		v.End = a.End.UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes an access control as returned by Cloudinary.
+func (a *AccessControl) UnmarshalJSON(data []byte) error {
+	var v accessControlJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	start, err := parseAccessTime(v.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseAccessTime(v.End)
+	if err != nil {
+		return err
+	}
+	*a = AccessControl{AccessType: v.AccessType, Start: start, End: end}
+	return nil
+}
+
+func parseAccessTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	var err error
+	for _, layout := range accessControlLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, v); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// UploadOptions holds optional parameters sent along with uploaded files.
+// Empty fields are not sent.
+type UploadOptions struct {
+	// AccessMode is either "public" (the default) or "authenticated".
+	// Authenticated resources are not publicly accessible.
+	AccessMode string
+	// AccessControl restricts the access to the uploaded resources. The
+	// settings are returned in the AccessControl field of the uploaded
+	// resource.
+	AccessControl []AccessControl
+	// Eager transformations are generated at upload time rather than on
+	// first delivery. They are returned in the Eager field of the
+	// uploaded resource.
+	Eager []Transformation
+	// EagerAsync generates eager transformations in the background. The
+	// upload response then holds no eager results: they are notified to
+	// EagerNotificationURL once ready, if set.
+	EagerAsync           bool
+	EagerNotificationURL string
+	// QualityAnalysis requests a quality analysis of uploaded images,
+	// returned in the QualityAnalysis field of the uploaded resource.
+	QualityAnalysis bool
+	// ImageMetadata requests the EXIF, IPTC and XMP metadata of uploaded
+	// images and Phash their perceptual hash, returned in the
+	// ImageMetadata and Phash fields of the uploaded resource.
+	ImageMetadata bool
+	Phash         bool
+	// Colors requests the main colors of uploaded images, returned in the
+	// Colors field of the uploaded resource, see Resource.DominantColor().
+	Colors bool
+	// UseFilename derives the public id of resources uploaded with a
+	// random public id from the original filename, followed by a random
+	// suffix. DiscardOriginalFilename does not store the original
+	// filename, e.g. to hide it from attachments: it can be combined with
+	// UseFilename, the public id being derived before the name is
+	// discarded. ExactFilename, with UseFilename, drops the random suffix.
+	UseFilename             bool
+	DiscardOriginalFilename bool
+	ExactFilename           bool
+	// Filename overrides the filename of the uploaded multipart file,
+	// which defaults to the base name of the uploaded path. Cloudinary
+	// uses its extension to detect the format, e.g. of raw files.
+	Filename string
+	// NoOverwrite makes uploads with a fixed public id fail with
+	// ErrAlreadyExists when a resource with that id exists, instead of
+	// replacing it with a new version. Cloudinary then answers with a
+	// 409 Conflict status.
+	NoOverwrite bool
+	// Backup keeps a backup copy of the uploaded resources, even if
+	// backups are not enabled for the account. The original URL and the
+	// backed up versions are returned in the OriginalSecureUrl and
+	// Versions fields of the uploaded resource.
+	Backup bool
+	// Format forces the stored format, e.g. jpg, when it can't be
+	// detected from the data. It must be one of the known formats.
+	Format string
+	// Eval is a JavaScript snippet run by Cloudinary before storing the
+	// upload, e.g. to set tags or metadata conditionally.
+	Eval string
+	// Categorization names the categorization add-ons to apply, e.g.
+	// google_tagging. With AutoTagging, between 0 and 1, the categories
+	// detected with at least that confidence are added as tags, returned
+	// in the Tags field of the uploaded resource.
+	Categorization string
+	AutoTagging    float64
+
+	// Folder of the random public id, set by UploadAuto()
+	folder string
+}
+
+// knownFormats lists the formats accepted by UploadOptions.Format.
+var knownFormats = map[string]bool{
+	"jpg": true, "png": true, "gif": true, "webp": true, "bmp": true,
+	"tiff": true, "heic": true, "pdf": true, "svg": true, "mp4": true,
+	"webm": true, "mov": true,
+}
+
+func validAccessMode(mode string) error {
+	if mode != AccessModePublic && mode != AccessModeAuthenticated {
+		return errors.New("invalid access mode: " + mode)
+	}
+	return nil
+}
+
+// validate checks the options values. A nil *UploadOptions is valid.
+func (o *UploadOptions) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.AccessMode != "" {
+		if err := validAccessMode(o.AccessMode); err != nil {
+			return err
+		}
+	}
+	for _, a := range o.AccessControl {
+		if a.AccessType != AccessTypeAnonymous && a.AccessType != AccessTypeToken {
+			return errors.New("invalid access type: " + a.AccessType)
+		}
+		if !a.Start.IsZero() && !a.End.IsZero() && !a.End.After(a.Start) {
+			return errors.New("access control ends before it starts")
+		}
+	}
+	for _, t := range o.Eager {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	if o.Eval != "" && strings.TrimSpace(o.Eval) == "" {
+		return errors.New("blank eval script")
+	}
+	if o.AutoTagging < 0 || o.AutoTagging > 1 {
+		return fmt.Errorf("auto tagging threshold must be between 0 and 1, got %g", o.AutoTagging)
+	}
+	if o.AutoTagging != 0 && o.Categorization == "" {
+		return errors.New("auto tagging requires a categorization add-on")
+	}
+	if o.ExactFilename && !o.UseFilename {
+		return errors.New("exact filename requires use filename")
+	}
+	if strings.ContainsAny(o.Filename, `/\`) {
+		return errors.New("upload filename must be a base name: " + o.Filename)
+	}
+	if o.Format != "" && !knownFormats[o.Format] {
+		return errors.New("unknown upload format: " + o.Format)
+	}
+	if o.EagerNotificationURL != "" {
+		u, err := url.Parse(o.EagerNotificationURL)
+		if err != nil {
+			return err
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("invalid eager notification URL: " + o.EagerNotificationURL)
+		}
+	}
+	return nil
+}
+
+// setParams adds the options to the signed upload parameters.
+func (o *UploadOptions) setParams(params map[string]string) {
+	if o == nil {
+		return
+	}
+	if o.AccessMode != "" {
+		params["access_mode"] = o.AccessMode
+	}
+	if o.folder != "" {
+		params["folder"] = o.folder
+	}
+	if len(o.AccessControl) > 0 {
+		if data, err := json.Marshal(o.AccessControl); err == nil {
+			params["access_control"] = string(data)
+		}
+	}
+	if len(o.Eager) > 0 {
+		eager := make([]string, 0, len(o.Eager))
+		for _, t := range o.Eager {
+			eager = append(eager, t.Encode())
+		}
+		params["eager"] = strings.Join(eager, "|")
+	}
+	if o.EagerAsync {
+		params["eager_async"] = "true"
+	}
+	if o.EagerNotificationURL != "" {
+		params["eager_notification_url"] = o.EagerNotificationURL
+	}
+	if o.QualityAnalysis {
+		params["quality_analysis"] = "true"
+	}
+	if o.ImageMetadata {
+		params["image_metadata"] = "true"
+	}
+	if o.Phash {
+		params["phash"] = "true"
+	}
+	if o.Colors {
+		params["colors"] = "true"
+	}
+	if o.UseFilename {
+		params["use_filename"] = "true"
+	}
+	if o.DiscardOriginalFilename {
+		params["discard_original_filename"] = "true"
+	}
+	if o.ExactFilename {
+		params["unique_filename"] = "false"
+	}
+	if o.Backup {
+		params["backup"] = "true"
+	}
+	if o.NoOverwrite {
+		params["overwrite"] = "false"
+		params["return_error"] = "true"
+	}
+	if o.Format != "" {
+		params["format"] = o.Format
+	}
+	if o.Eval != "" {
+		params["eval"] = o.Eval
+	}
+	if o.Categorization != "" {
+		params["categorization"] = o.Categorization
+	}
+	if o.AutoTagging != 0 {
+		params["auto_tagging"] = strconv.FormatFloat(o.AutoTagging, 'f', -1, 64)
+	}
+}
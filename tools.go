@@ -6,10 +6,19 @@
 package cloudinary
 
 import (
+	"bytes"
 	"crypto/sha1"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Returns SHA1 file checksum
@@ -22,3 +31,186 @@ func fileChecksum(path string) (string, error) {
 	io.WriteString(hash, string(data))
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
+
+// Returns the keys of m in alphabetical order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Clock of rate limited readers, replaced by tests.
+var (
+	rateLimitNow   = time.Now
+	rateLimitSleep = time.Sleep
+)
+
+// rateLimitedReader is a reader that never delivers more than rate
+// bytes per second.
+type rateLimitedReader struct {
+	r     io.Reader
+	rate  int64 // In bytes per second
+	read  int64 // Bytes read so far
+	start time.Time
+}
+
+func newRateLimitedReader(r io.Reader, bytesPerSec int64) *rateLimitedReader {
+	return &rateLimitedReader{r: r, rate: bytesPerSec}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	if l.start.IsZero() {
+		l.start = rateLimitNow()
+	}
+	if int64(len(p)) > l.rate {
+		p = p[:l.rate]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	// Wait until the bytes read so far fit in the allowed rate
+	expected := time.Duration(float64(l.read) / float64(l.rate) * float64(time.Second))
+	if wait := expected - rateLimitNow().Sub(l.start); wait > 0 {
+		rateLimitSleep(wait)
+	}
+	return n, err
+}
+
+// rewindable returns a reader of the content left in r that can be read
+// again from the start, or nil if r can't be rewound (it must be both an
+// io.ReaderAt and an io.Seeker, like files, bytes.Reader and
+// strings.Reader). The position of r is left unchanged.
+func rewindable(r io.Reader) (*io.SectionReader, error) {
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		return nil, nil
+	}
+	rs, ok := r.(io.Seeker)
+	if !ok {
+		return nil, nil
+	}
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(ra, start, end-start), nil
+}
+
+// syncHash is a hash that can be read while the transport may still be
+// writing to it.
+type syncHash struct {
+	mu sync.Mutex
+	h  hash.Hash
+}
+
+func (h *syncHash) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.h.Write(p)
+}
+
+// sum returns the hex encoded checksum of the bytes written so far.
+func (h *syncHash) sum() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return fmt.Sprintf("%x", h.h.Sum(nil))
+}
+
+// smartEscape percent-encodes all characters of a URL but unreserved
+// ones and the / and : separators, as expected by Cloudinary for remote
+// URLs used as public ids.
+func smartEscape(s string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("_.-/:", c) != -1 {
+			buf.WriteByte(c)
+		} else {
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+// cancelCloser cancels a request context when the response body is closed.
+type cancelCloser struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelCloser) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// bufferBody reads the body of req, up to max bytes, so that it can be
+// sent again: GetBody is set accordingly. Larger bodies are left without
+// GetBody, the part already read being sent first.
+func bufferBody(req *http.Request, max int64) error {
+	buf, err := ioutil.ReadAll(io.LimitReader(req.Body, max+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(buf)) > max {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), req.Body), req.Body}
+		return nil
+	}
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(buf)), nil
+	}
+	return nil
+}
+
+// escapeContext escapes the characters used as separators in contextual
+// metadata (key1=value1|key2=value2).
+func escapeContext(v string) string {
+	return strings.NewReplacer("=", `\=`, "|", `\|`).Replace(v)
+}
+
+// encodeMetadata serializes metadata fields as key1=value1|key2=value2,
+// keys being sorted.
+func encodeMetadata(fields map[string]string) string {
+	parts := make([]string, 0, len(fields))
+	for _, k := range sortedKeys(fields) {
+		parts = append(parts, escapeContext(k)+"="+escapeContext(fields[k]))
+	}
+	return strings.Join(parts, "|")
+}
+
+// attachmentName strips filename of its extension and replaces any
+// character which is not a letter, a digit, - or _ with _, for use with
+// the attachment flag.
+func attachmentName(filename string) string {
+	filename = strings.TrimSuffix(filename, path.Ext(filename))
+	return strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, filename)
+}
+
+// escapePublicId escapes a public id for use in a URL path, preserving
+// the / folder separators. + is escaped too so it isn't read as a space.
+func escapePublicId(publicId string) string {
+	segs := strings.Split(publicId, "/")
+	for k, seg := range segs {
+		segs[k] = strings.Replace(url.PathEscape(seg), "+", "%2B", -1)
+	}
+	return strings.Join(segs, "/")
+}
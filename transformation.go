@@ -0,0 +1,480 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudinary
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Transformation describes how a resource is transformed on delivery.
+// Empty fields are omitted from the generated URL.
+type Transformation struct {
+	// If makes the transformation conditional, e.g. w_gt_500. The special
+	// values else and end respectively start the alternative branch and
+	// close the conditional block in a chain, see UrlChained().
+	If      string
+	Named   string  // Named transformation, see CreateTransformation()
+	Crop    string  // Crop mode, e.g. fill, fit or thumb; unknown modes are passed through
+	Gravity string  // e.g. face, center or north_east
+	Zoom    float64 // e.g. 0.7 to zoom out of a face with the thumb crop mode
+	// X and Y locate the top left corner of the area kept by the crop
+	// mode, in pixels. They are always sent with the crop mode, even if
+	// 0: set Gravity to center for a centered crop.
+	X       int
+	Y       int
+	Width   int
+	Height  int
+	Quality string       // 1 to 100 or auto
+	Overlay *TextOverlay // Text drawn over the resource, can be nil
+	// Effect applied to the resource, e.g. sepia or background_removal,
+	// the latter requiring the Cloudinary AI Background Removal add-on.
+	Effect string
+	Format string   // Delivery format, e.g. auto to pick the best one for the browser
+	Flags  []string // e.g. progressive, attachment or lossy
+}
+
+// TextOverlay describes a caption drawn over a resource, e.g. with Font
+// Arial and Size 40.
+type TextOverlay struct {
+	Text string
+	Font string
+	Size int
+}
+
+// Encode returns the overlay transformation component, e.g.
+// l_text:Arial_40:Hello%20World. The text is escaped so that spaces and
+// punctuation survive, commas and slashes being escaped twice as required
+// by Cloudinary.
+func (o TextOverlay) Encode() string {
+	text := url.PathEscape(o.Text)
+	text = strings.NewReplacer("%2C", "%252C", "%2F", "%252F").Replace(text)
+	return fmt.Sprintf("l_text:%s_%d:%s", o.Font, o.Size, text)
+}
+
+// Encode returns the transformation string, e.g. c_fill,w_300,h_200, as
+// used in URL segments or named transformation definitions. It is empty
+// if no field is set.
+func (t Transformation) Encode() string {
+	parts := make([]string, 0)
+	if t.If != "" {
+		parts = append(parts, "if_"+t.If)
+	}
+	if t.Named != "" {
+		parts = append(parts, "t_"+t.Named)
+	}
+	if t.Crop != "" {
+		parts = append(parts, "c_"+t.Crop)
+	}
+	if t.Gravity != "" {
+		parts = append(parts, "g_"+t.Gravity)
+	}
+	if t.Zoom != 0 {
+		parts = append(parts, "z_"+strconv.FormatFloat(t.Zoom, 'f', -1, 64))
+	}
+	// A crop without offsets is centered: 0 is a meaningful value there
+	if t.X != 0 || t.Crop == "crop" {
+		parts = append(parts, fmt.Sprintf("x_%d", t.X))
+	}
+	if t.Y != 0 || t.Crop == "crop" {
+		parts = append(parts, fmt.Sprintf("y_%d", t.Y))
+	}
+	if t.Width != 0 {
+		parts = append(parts, fmt.Sprintf("w_%d", t.Width))
+	}
+	if t.Height != 0 {
+		parts = append(parts, fmt.Sprintf("h_%d", t.Height))
+	}
+	if t.Quality != "" {
+		parts = append(parts, "q_"+t.Quality)
+	}
+	if t.Overlay != nil {
+		parts = append(parts, t.Overlay.Encode())
+	}
+	if t.Effect != "" {
+		parts = append(parts, "e_"+t.Effect)
+	}
+	if t.Format != "" {
+		parts = append(parts, "f_"+t.Format)
+	}
+	if len(t.Flags) > 0 {
+		parts = append(parts, "fl_"+strings.Join(t.Flags, "."))
+	}
+	return strings.Join(parts, ",")
+}
+
+// cropModes lists the crop modes known to this package. Other modes are
+// still accepted, so that new Cloudinary modes can be used right away.
+// The imagga_crop and imagga_scale content-aware modes require the
+// Imagga add-on.
+var cropModes = map[string]bool{
+	"scale": true, "fit": true, "limit": true, "mfit": true, "fill": true,
+	"lfill": true, "fill_pad": true, "pad": true, "lpad": true, "mpad": true,
+	"crop": true, "thumb": true, "imagga_crop": true, "imagga_scale": true,
+	"auto": true,
+}
+
+// namedTransformation matches valid named transformation names.
+var namedTransformation = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// sizedCropModes lists the crop modes requiring a width or a height.
+var sizedCropModes = map[string]bool{
+	"fill": true, "crop": true, "fit": true, "scale": true, "pad": true,
+}
+
+// gravities lists the valid gravity values. They can be combined with :,
+// e.g. faces:center or custom:face, and any value can follow auto:.
+var gravities = map[string]bool{
+	"north_west": true, "north": true, "north_east": true,
+	"west": true, "center": true, "east": true,
+	"south_west": true, "south": true, "south_east": true,
+	"xy_center": true, "face": true, "faces": true, "body": true,
+	"adv_face": true, "adv_faces": true, "adv_eyes": true,
+	"custom": true, "liquid": true, "ocr_text": true, "auto": true,
+}
+
+// validGravity tells whether g is a known, possibly compound, gravity.
+func validGravity(g string) bool {
+	if strings.HasPrefix(g, "auto:") {
+		return true
+	}
+	for _, p := range strings.Split(g, ":") {
+		if !gravities[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// validQuality tells whether q is a quality between 1 and 100, optionally
+// followed by a chroma subsampling (e.g. 80:420), or an auto or jpegmini
+// quality (e.g. auto:good).
+func validQuality(q string) bool {
+	base := strings.SplitN(q, ":", 2)[0]
+	if base == "auto" || base == "jpegmini" {
+		return true
+	}
+	n, err := strconv.Atoi(base)
+	return err == nil && n >= 1 && n <= 100
+}
+
+// Validate checks the transformation values. Unknown crop modes are
+// only logged. The fill, crop, fit, scale and pad crop modes require a
+// width or a height, and dimensions can't be negative. Quality is between
+// 1 and 100, or an auto or jpegmini quality. Gravity is a known, possibly
+// compound, value. Named transformations only hold letters, digits, _ and
+// -. Text overlays need a text, a font and a size, and flags can't be
+// empty.
+func (t Transformation) Validate() error {
+	if t.Named != "" && !namedTransformation.MatchString(t.Named) {
+		return errors.New("invalid named transformation: " + t.Named)
+	}
+	if t.Width < 0 || t.Height < 0 {
+		return fmt.Errorf("negative transformation dimensions: %dx%d", t.Width, t.Height)
+	}
+	if t.Crop != "" && !cropModes[t.Crop] {
+		log.Printf("warning: unknown crop mode %s, passed through as is", t.Crop)
+	}
+	if sizedCropModes[t.Crop] && t.Width == 0 && t.Height == 0 {
+		return errors.New("crop mode " + t.Crop + " requires a width or a height")
+	}
+	if t.Quality != "" && !validQuality(t.Quality) {
+		return errors.New("invalid quality: " + t.Quality)
+	}
+	if t.Gravity != "" && !validGravity(t.Gravity) {
+		return errors.New("invalid gravity: " + t.Gravity)
+	}
+	if o := t.Overlay; o != nil && (o.Text == "" || o.Font == "" || o.Size <= 0) {
+		return errors.New("text overlay requires a text, a font and a size")
+	}
+	for _, f := range t.Flags {
+		if f == "" {
+			return errors.New("empty transformation flag")
+		}
+	}
+	return nil
+}
+
+// EncodeTransformations returns the transformation string of chained
+// transformations, separated by /, e.g. c_fill,w_300/e_sepia. Empty
+// steps are omitted.
+func EncodeTransformations(steps []Transformation) string {
+	parts := make([]string, 0, len(steps))
+	for _, t := range steps {
+		if e := t.Encode(); e != "" {
+			parts = append(parts, e)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// encodeChain is like EncodeTransformations() but validates every step and
+// makes sure that every if_ condition is closed by a matching if_end.
+func encodeChain(steps []Transformation) (string, error) {
+	open := false
+	for _, t := range steps {
+		if err := t.Validate(); err != nil {
+			return "", err
+		}
+		switch t.If {
+		case "":
+		case "else":
+			if !open {
+				return "", errors.New("if_else without if_ condition")
+			}
+		case "end":
+			if !open {
+				return "", errors.New("if_end without if_ condition")
+			}
+			open = false
+		default:
+			if open {
+				return "", errors.New("nested if_ conditions are not supported")
+			}
+			open = true
+		}
+	}
+	if open {
+		return "", errors.New("if_ condition without matching if_end")
+	}
+	return EncodeTransformations(steps), nil
+}
+
+// VideoTransformation adds video specific settings to a Transformation.
+// It only applies to video resources, see VideoUrl().
+type VideoTransformation struct {
+	Transformation
+	StartOffset float64 // In seconds
+	EndOffset   float64 // In seconds
+	VideoCodec  string  // e.g. h264 or vp9
+	BitRate     string  // e.g. 500k or 2m
+}
+
+// Encode returns the transformation string, e.g. w_300,so_2.0,vc_h264.
+func (t VideoTransformation) Encode() string {
+	parts := make([]string, 0)
+	if base := t.Transformation.Encode(); base != "" {
+		parts = append(parts, base)
+	}
+	if t.StartOffset != 0 {
+		parts = append(parts, "so_"+formatSeconds(t.StartOffset))
+	}
+	if t.EndOffset != 0 {
+		parts = append(parts, "eo_"+formatSeconds(t.EndOffset))
+	}
+	if t.VideoCodec != "" {
+		parts = append(parts, "vc_"+t.VideoCodec)
+	}
+	if t.BitRate != "" {
+		parts = append(parts, "br_"+t.BitRate)
+	}
+	return strings.Join(parts, ",")
+}
+
+// formatSeconds formats an offset with at least one decimal, e.g. 2.0.
+func formatSeconds(secs float64) string {
+	f := strconv.FormatFloat(secs, 'f', -1, 64)
+	if !strings.Contains(f, ".") {
+		f += ".0"
+	}
+	return f
+}
+
+// resourceBase returns the base URL of delivered resources.
+func (s *Service) resourceBase() string {
+	if s.resourceURI != nil {
+		return strings.TrimSuffix(s.resourceURI.String(), "/")
+	}
+	if s.secure {
+		return baseSecureResourceUrl
+	}
+	return baseResourceUrl
+}
+
+// deliveryUrl returns the delivery URL of a resource with an optional
+// transformation segment.
+func (s *Service) deliveryUrl(rtype ResourceType, transformation, publicId string) string {
+	publicId = escapePublicId(publicId)
+	if transformation != "" {
+		publicId = transformation + "/" + publicId
+	}
+	return fmt.Sprintf("%s/%s/%s/upload/%s", s.resourceBase(), s.cloudName, typeName(rtype), publicId)
+}
+
+// VideoUrl returns the access path in the cloud to the video designed
+// by publicId, transformed according to t.
+func (s *Service) VideoUrl(publicId string, t VideoTransformation) string {
+	return s.deliveryUrl(VideoType, t.Encode(), publicId)
+}
+
+// VideoThumbnail returns the URL of a JPEG still frame taken from the
+// video designed by publicId at the given offset, 0 being the first
+// frame, transformed according to t. No API call is made.
+func (s *Service) VideoThumbnail(publicId string, atSeconds float64, t Transformation) string {
+	vt := VideoTransformation{Transformation: t, StartOffset: atSeconds}
+	tr := vt.Encode()
+	if atSeconds == 0 {
+		// Without offset, Cloudinary takes the middle frame
+		so := "so_" + formatSeconds(0)
+		if tr != "" {
+			so = "," + so
+		}
+		tr += so
+	}
+	publicId = publicId[:len(publicId)-len(filepath.Ext(publicId))] + ".jpg"
+	return s.deliveryUrl(VideoType, tr, publicId)
+}
+
+// AttachmentUrl returns the URL of the resource designed by publicId
+// which makes browsers download it instead of displaying it. The
+// downloaded file is named after filename if not empty, or after the
+// public id. Its extension is always the one of the delivered format.
+func (s *Service) AttachmentUrl(publicId string, rtype ResourceType, filename string) string {
+	flag := "attachment"
+	if name := attachmentName(filename); name != "" {
+		flag += ":" + name
+	}
+	return s.deliveryUrl(rtype, Transformation{Flags: []string{flag}}.Encode(), publicId)
+}
+
+// faceThumbSize is the size of the square thumbnails built by
+// FaceCropUrl().
+const faceThumbSize = 200
+
+// FaceCropUrl returns the URL of a square thumbnail of the face found in
+// the image designed by publicId, at the rectangle face given as [x, y,
+// width, height] in pixels, as reported by face detection. It returns an
+// empty string if face is not a valid rectangle.
+func (s *Service) FaceCropUrl(publicId string, face []int) string {
+	if len(face) != 4 || face[0] < 0 || face[1] < 0 || face[2] <= 0 || face[3] <= 0 {
+		return ""
+	}
+	steps := []Transformation{
+		{Crop: "crop", X: face[0], Y: face[1], Width: face[2], Height: face[3]},
+		{Crop: "fill", Width: faceThumbSize, Height: faceThumbSize},
+	}
+	return s.deliveryUrl(ImageType, EncodeTransformations(steps), publicId)
+}
+
+// SrcSet returns the value of the srcset attribute of an HTML img element
+// showing the resource designed by publicId, e.g.
+// "<url with w_320> 320w, <url with w_640> 640w". Widths that are not
+// positive are skipped.
+func (s *Service) SrcSet(publicId string, rtype ResourceType, widths []int) string {
+	set := make([]string, 0, len(widths))
+	for _, w := range widths {
+		if w <= 0 {
+			continue
+		}
+		u := s.deliveryUrl(rtype, Transformation{Width: w}.Encode(), publicId)
+		set = append(set, fmt.Sprintf("%s %dw", u, w))
+	}
+	return strings.Join(set, ", ")
+}
+
+// Default width and blur strength of low quality image placeholders.
+const (
+	lqipWidth = 20
+	lqipBlur  = 1000
+)
+
+// LQIPUrl returns the URL of a tiny, blurred and low quality version of
+// the resource designed by publicId, to be displayed while the full
+// resource loads.
+func (s *Service) LQIPUrl(publicId string, rtype ResourceType) string {
+	return s.LQIPUrlWith(publicId, rtype, 0, 0)
+}
+
+// LQIPUrlWith is like LQIPUrl() with the given width, in pixels, and blur
+// strength, between 1 and 2000. Zero values use the defaults of 20 and
+// 1000.
+func (s *Service) LQIPUrlWith(publicId string, rtype ResourceType, width, blur int) string {
+	if width <= 0 {
+		width = lqipWidth
+	}
+	if blur <= 0 {
+		blur = lqipBlur
+	}
+	t := Transformation{Width: width, Quality: "1", Effect: fmt.Sprintf("blur:%d", blur), Format: "auto"}
+	return s.deliveryUrl(rtype, t.Encode(), publicId)
+}
+
+// FetchUrl returns the URL delivering the remote image at remoteURL
+// through Cloudinary, transformed according to t.
+func (s *Service) FetchUrl(remoteURL string, t Transformation) string {
+	return s.fetchUrl(remoteURL, t, false)
+}
+
+// SignedFetchUrl is like FetchUrl() but adds a signature to the URL, as
+// required by accounts restricting fetched URLs to signed ones.
+func (s *Service) SignedFetchUrl(remoteURL string, t Transformation) string {
+	return s.fetchUrl(remoteURL, t, true)
+}
+
+func (s *Service) fetchUrl(remoteURL string, t Transformation, signed bool) string {
+	path := smartEscape(remoteURL)
+	if tr := t.Encode(); tr != "" {
+		path = tr + "/" + path
+	}
+	if signed {
+		path = s.urlSignature(path) + "/" + path
+	}
+	return fmt.Sprintf("%s/%s/%s/fetch/%s", s.resourceBase(), s.cloudName, imageType, path)
+}
+
+// OptimizerUrl returns the short URL delivering remoteURL, an asset of
+// the origin registered with the media optimizer, through the optimizer
+// host set with OptimizerURI(). The path of the asset on its origin
+// follows the named transformation set with OptimizerTransformation(),
+// e.g. https://media.example.com/t_web/images/logo.png, the query string
+// being kept. It returns an empty string if the optimizer host or
+// transformation is not set or remoteURL is invalid.
+func (s *Service) OptimizerUrl(remoteURL string) string {
+	if s.optimizerURI == nil || s.optimizerTr == "" {
+		return ""
+	}
+	u, err := url.Parse(remoteURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	opt := *s.optimizerURI
+	opt.Path = strings.TrimSuffix(opt.Path, "/") + "/t_" + s.optimizerTr + "/" + strings.TrimPrefix(u.Path, "/")
+	opt.RawPath = ""
+	opt.RawQuery = u.RawQuery
+	return opt.String()
+}
+
+// urlSignature returns the signature component of a signed delivery URL,
+// computed over path (everything following the signature in the URL).
+func (s *Service) urlSignature(path string) string {
+	hash := sha1.Sum([]byte(path + s.apiSecret))
+	return "s--" + base64.URLEncoding.EncodeToString(hash[:])[:8] + "--"
+}
+
+// UrlChained returns the access path in the cloud to the resource
+// designed by publicId, with the transformation steps applied in order.
+// Conditional steps are built with the If field:
+//  []Transformation{
+//  	{If: "w_gt_500", Crop: "fill", Width: 500},
+//  	{If: "else", Crop: "fit", Width: 200},
+//  	{If: "end"},
+//  }
+// produces if_w_gt_500,c_fill,w_500/if_else,c_fit,w_200/if_end.
+func (s *Service) UrlChained(publicId string, rtype ResourceType, steps []Transformation) (string, error) {
+	chain, err := encodeChain(steps)
+	if err != nil {
+		return "", err
+	}
+	return s.deliveryUrl(rtype, chain, publicId), nil
+}
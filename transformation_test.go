@@ -0,0 +1,511 @@
+// Copyright 2013 Mathias Monnerville and Anthony Baillard.
+// All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+package cloudinary
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	trans := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{}, ""},
+		{Transformation{Width: 300}, "w_300"},
+		{Transformation{Crop: "fill", Gravity: "face", Width: 300, Height: 200, Quality: "80"}, "c_fill,g_face,w_300,h_200,q_80"},
+		{Transformation{Crop: "fit", Height: 100}, "c_fit,h_100"},
+	}
+	for _, tr := range trans {
+		if got := tr.t.Encode(); got != tr.expected {
+			t.Errorf("wrong encoding. Expect '%s', got '%s'", tr.expected, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []Transformation{
+		{Crop: "fill"},
+		{Crop: "crop", Gravity: "face"},
+		{Width: -300},
+		{Quality: "0"},
+		{Quality: "101"},
+		{Quality: "best"},
+		{Gravity: "top"},
+		{Gravity: "face:top"},
+		{Flags: []string{""}},
+	}
+	for _, tr := range invalid {
+		if err := tr.Validate(); err == nil {
+			t.Errorf("should fail on invalid transformation %+v", tr)
+		}
+	}
+	valid := []Transformation{
+		{Crop: "fill", Gravity: "auto:subject", Width: 300, Quality: "80:420"},
+		{Crop: "crop", Gravity: "xy_center", Width: 100, X: 10, Y: 20},
+		{Crop: "thumb", Gravity: "face:center", Quality: "auto:good"},
+		{Crop: "thumb", Gravity: "faces:center", Quality: "jpegmini"},
+		{Crop: "fill", Gravity: "custom:face", Width: 100, Quality: "jpegmini:1"},
+		{Crop: "fill", Gravity: "ocr_text", Width: 100},
+		{Crop: "scale", Gravity: "liquid", Width: 100},
+	}
+	for _, tr := range valid {
+		if err := tr.Validate(); err != nil {
+			t.Errorf("expected no error on valid transformation %+v, got %v", tr, err)
+		}
+	}
+	s := cloudinaryService()
+	if _, err := s.UrlChained("logo", ImageType, []Transformation{{Crop: "fill"}}); err == nil {
+		t.Error("UrlChained() should fail on invalid transformation")
+	}
+}
+
+func TestRawCropMode(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/image/upload/"
+	crops := []struct {
+		crop    string
+		url     string
+		warning bool
+	}{
+		{"fill_pad", base + "c_fill_pad,w_300,h_200/logo", false},
+		{"auto", base + "c_auto,w_300,h_200/logo", false},
+		{"future_mode", base + "c_future_mode,w_300,h_200/logo", true},
+	}
+	for _, c := range crops {
+		buf.Reset()
+		u, err := s.UrlChained("logo", ImageType, []Transformation{{Crop: c.crop, Width: 300, Height: 200}})
+		if err != nil {
+			t.Fatalf("expected no error with crop mode %s, got %v", c.crop, err)
+		}
+		if u != c.url {
+			t.Errorf("wrong url. Expect %s, got %s", c.url, u)
+		}
+		if warned := strings.Contains(buf.String(), "unknown crop mode"); warned != c.warning {
+			t.Errorf("wrong warning for crop mode %s. Expect %v, got %v", c.crop, c.warning, warned)
+		}
+	}
+}
+
+func TestZoom(t *testing.T) {
+	zooms := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{Crop: "thumb", Gravity: "face", Zoom: 0.7}, "c_thumb,g_face,z_0.7"},
+		{Transformation{Crop: "thumb", Gravity: "face", Zoom: 1.5, Width: 150, Height: 150}, "c_thumb,g_face,z_1.5,w_150,h_150"},
+		{Transformation{Crop: "thumb", Gravity: "face", Zoom: 0}, "c_thumb,g_face"},
+	}
+	for _, z := range zooms {
+		if got := z.t.Encode(); got != z.expected {
+			t.Errorf("wrong zoom encoding. Expect '%s', got '%s'", z.expected, got)
+		}
+	}
+}
+
+func TestNamedTransformation(t *testing.T) {
+	s := cloudinaryService()
+	steps := []Transformation{
+		{Named: "base"},
+		{Crop: "fill", Width: 300},
+	}
+	u, err := s.UrlChained("products/shoe", ImageType, steps)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	expected := baseResourceUrl + "/cloudname/image/upload/t_base/c_fill,w_300/products/shoe"
+	if u != expected {
+		t.Errorf("wrong url. Expect %s, got %s", expected, u)
+	}
+	for _, name := range []string{"my-base_2", "Thumb"} {
+		if err := (Transformation{Named: name}).Validate(); err != nil {
+			t.Errorf("expected no error to occur for %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"a/b", "a,w_100", "a b", "base.jpg"} {
+		if _, err := s.UrlChained("products/shoe", ImageType, []Transformation{{Named: name}}); err == nil {
+			t.Errorf("should fail on invalid named transformation %s", name)
+		}
+	}
+}
+
+func TestEffect(t *testing.T) {
+	s := cloudinaryService()
+	steps := []Transformation{
+		{Effect: "background_removal"},
+		{Crop: "fill", Width: 300, Height: 300, Quality: "auto"},
+	}
+	u, err := s.UrlChained("products/shoe", ImageType, steps)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	expected := baseResourceUrl + "/cloudname/image/upload/e_background_removal/c_fill,w_300,h_300,q_auto/products/shoe"
+	if u != expected {
+		t.Errorf("wrong url. Expect %s, got %s", expected, u)
+	}
+	tr := Transformation{Width: 300, Effect: "background_removal"}
+	if got := tr.Encode(); got != "w_300,e_background_removal" {
+		t.Errorf("wrong effect encoding. Expect '%s', got '%s'", "w_300,e_background_removal", got)
+	}
+}
+
+func TestSrcSet(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/image/upload/"
+	expected := base + "w_320/products/shoe 320w, " + base + "w_640/products/shoe 640w, " + base + "w_1280/products/shoe 1280w"
+	if got := s.SrcSet("products/shoe", ImageType, []int{320, 640, 1280}); got != expected {
+		t.Errorf("wrong srcset. Expect %s, got %s", expected, got)
+	}
+	if got := s.SrcSet("products/shoe", ImageType, []int{0, -1}); got != "" {
+		t.Errorf("wrong srcset without valid widths. Expect empty string, got %s", got)
+	}
+}
+
+func TestLQIPUrl(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/image/upload/"
+	if u, expected := s.LQIPUrl("products/shoe", ImageType), base+"w_20,q_1,e_blur:1000,f_auto/products/shoe"; u != expected {
+		t.Errorf("wrong LQIP url. Expect %s, got %s", expected, u)
+	}
+	urls := []struct {
+		width, blur int
+		expected    string
+	}{
+		{40, 0, base + "w_40,q_1,e_blur:1000,f_auto/products/shoe"},
+		{0, 500, base + "w_20,q_1,e_blur:500,f_auto/products/shoe"},
+		{64, 2000, base + "w_64,q_1,e_blur:2000,f_auto/products/shoe"},
+	}
+	for _, u := range urls {
+		if got := s.LQIPUrlWith("products/shoe", ImageType, u.width, u.blur); got != u.expected {
+			t.Errorf("wrong LQIP url. Expect %s, got %s", u.expected, got)
+		}
+	}
+}
+
+func TestManualCrop(t *testing.T) {
+	crops := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{Crop: "crop", X: 100, Y: 50, Width: 300, Height: 200}, "c_crop,x_100,y_50,w_300,h_200"},
+		{Transformation{Crop: "crop", X: -20, Y: -35, Width: 300, Height: 200}, "c_crop,x_-20,y_-35,w_300,h_200"},
+		{Transformation{Crop: "crop", X: 0, Y: -10, Width: 300, Height: 200}, "c_crop,x_0,y_-10,w_300,h_200"},
+		{Transformation{Crop: "crop", Width: 300, Height: 200}, "c_crop,x_0,y_0,w_300,h_200"},
+		{Transformation{Crop: "crop", Gravity: "center", Width: 300, Height: 200}, "c_crop,g_center,x_0,y_0,w_300,h_200"},
+		{Transformation{Crop: "fill", X: 0, Y: 0, Width: 300}, "c_fill,w_300"},
+	}
+	for _, c := range crops {
+		if err := c.t.Validate(); err != nil {
+			t.Errorf("expected no error to occur for %s: %v", c.expected, err)
+		}
+		if got := c.t.Encode(); got != c.expected {
+			t.Errorf("wrong crop encoding. Expect '%s', got '%s'", c.expected, got)
+		}
+	}
+	s := cloudinaryService()
+	u, err := s.UrlChained("photos/beach", ImageType, []Transformation{crops[1].t, {Crop: "scale", Width: 100}})
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	expected := baseResourceUrl + "/cloudname/image/upload/c_crop,x_-20,y_-35,w_300,h_200/c_scale,w_100/photos/beach"
+	if u != expected {
+		t.Errorf("wrong url. Expect %s, got %s", expected, u)
+	}
+}
+
+func TestImaggaCrop(t *testing.T) {
+	crops := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{Crop: "imagga_crop", Width: 300, Height: 300}, "c_imagga_crop,w_300,h_300"},
+		{Transformation{Crop: "imagga_scale", Width: 640}, "c_imagga_scale,w_640"},
+		{Transformation{Crop: "imagga_crop"}, "c_imagga_crop"},
+	}
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	for _, c := range crops {
+		if err := c.t.Validate(); err != nil {
+			t.Errorf("expected no error to occur for %s: %v", c.expected, err)
+		}
+		if got := c.t.Encode(); got != c.expected {
+			t.Errorf("wrong crop encoding. Expect '%s', got '%s'", c.expected, got)
+		}
+	}
+	if buf.Len() != 0 {
+		t.Errorf("imagga crop modes should be known, got warning: %s", buf.String())
+	}
+	s := cloudinaryService()
+	u, err := s.UrlChained("products/shoe", ImageType, []Transformation{crops[0].t})
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	if expected := baseResourceUrl + "/cloudname/image/upload/c_imagga_crop,w_300,h_300/products/shoe"; u != expected {
+		t.Errorf("wrong url. Expect %s, got %s", expected, u)
+	}
+}
+
+func TestImproveEffect(t *testing.T) {
+	effects := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{Effect: "improve"}, "e_improve"},
+		{Transformation{Effect: "improve:outdoor"}, "e_improve:outdoor"},
+		{Transformation{Crop: "fill", Width: 400, Height: 300, Effect: "improve:indoor:50"}, "c_fill,w_400,h_300,e_improve:indoor:50"},
+	}
+	for _, e := range effects {
+		if got := e.t.Encode(); got != e.expected {
+			t.Errorf("wrong effect encoding. Expect '%s', got '%s'", e.expected, got)
+		}
+	}
+	s := cloudinaryService()
+	u, err := s.UrlChained("users/42", ImageType, []Transformation{{Effect: "improve:outdoor"}, {Crop: "fill", Width: 200, Height: 200}})
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	expected := baseResourceUrl + "/cloudname/image/upload/e_improve:outdoor/c_fill,w_200,h_200/users/42"
+	if u != expected {
+		t.Errorf("wrong url. Expect %s, got %s", expected, u)
+	}
+}
+
+func TestTextOverlay(t *testing.T) {
+	overlays := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{Overlay: &TextOverlay{Text: "Hello", Font: "Arial", Size: 40}}, "l_text:Arial_40:Hello"},
+		{Transformation{Width: 300, Overlay: &TextOverlay{Text: "Hello World!", Font: "Arial", Size: 40}}, "w_300,l_text:Arial_40:Hello%20World%21"},
+		{Transformation{Overlay: &TextOverlay{Text: "50% off, 1/2 price?", Font: "Verdana", Size: 12}}, "l_text:Verdana_12:50%25%20off%252C%201%252F2%20price%3F"},
+	}
+	for _, o := range overlays {
+		if got := o.t.Encode(); got != o.expected {
+			t.Errorf("wrong overlay encoding. Expect '%s', got '%s'", o.expected, got)
+		}
+	}
+	if err := (Transformation{Overlay: &TextOverlay{Text: "Hello", Font: "Arial"}}).Validate(); err == nil {
+		t.Error("should fail on text overlay without size")
+	}
+}
+
+func TestFlags(t *testing.T) {
+	flags := []struct {
+		t        Transformation
+		expected string
+	}{
+		{Transformation{Flags: []string{"progressive"}}, "fl_progressive"},
+		{Transformation{Flags: []string{"progressive", "lossy"}}, "fl_progressive.lossy"},
+		{Transformation{Width: 300, Flags: []string{"attachment", "force_strip"}}, "w_300,fl_attachment.force_strip"},
+	}
+	for _, f := range flags {
+		if got := f.t.Encode(); got != f.expected {
+			t.Errorf("wrong flags encoding. Expect '%s', got '%s'", f.expected, got)
+		}
+	}
+	s := cloudinaryService()
+	if _, err := s.UrlChained("logo", ImageType, []Transformation{{Flags: []string{"lossy", ""}}}); err == nil {
+		t.Error("should fail on empty flag")
+	}
+}
+
+func TestEncodeTransformations(t *testing.T) {
+	chains := []struct {
+		steps    []Transformation
+		expected string
+	}{
+		{nil, ""},
+		{[]Transformation{{Width: 300}}, "w_300"},
+		{[]Transformation{{Crop: "fill", Width: 300}, {}, {Quality: "auto"}}, "c_fill,w_300/q_auto"},
+	}
+	for _, c := range chains {
+		if got := EncodeTransformations(c.steps); got != c.expected {
+			t.Errorf("wrong chained encoding. Expect '%s', got '%s'", c.expected, got)
+		}
+	}
+}
+
+func TestVideoUrl(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/video/upload/"
+	videos := []struct {
+		t        VideoTransformation
+		expected string
+	}{
+		{VideoTransformation{}, base + "dog"},
+		{VideoTransformation{StartOffset: 2, EndOffset: 5}, base + "so_2.0,eo_5.0/dog"},
+		{VideoTransformation{StartOffset: 2.5}, base + "so_2.5/dog"},
+		{VideoTransformation{VideoCodec: "h264", BitRate: "500k"}, base + "vc_h264,br_500k/dog"},
+		{
+			VideoTransformation{StartOffset: 2, EndOffset: 5, VideoCodec: "h264", BitRate: "500k"},
+			base + "so_2.0,eo_5.0,vc_h264,br_500k/dog",
+		},
+		{
+			VideoTransformation{Transformation: Transformation{Width: 300}, VideoCodec: "vp9"},
+			base + "w_300,vc_vp9/dog",
+		},
+	}
+	for _, v := range videos {
+		if got := s.VideoUrl("dog", v.t); got != v.expected {
+			t.Errorf("wrong video url. Expect %s, got %s", v.expected, got)
+		}
+	}
+}
+
+func TestVideoThumbnail(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/video/upload/"
+	thumbs := []struct {
+		publicId  string
+		atSeconds float64
+		t         Transformation
+		expected  string
+	}{
+		{"dog", 2, Transformation{}, base + "so_2.0/dog.jpg"},
+		{"clips/dog.mp4", 3.5, Transformation{}, base + "so_3.5/clips/dog.jpg"},
+		{"dog", 2, Transformation{Crop: "fill", Width: 300, Height: 200}, base + "c_fill,w_300,h_200,so_2.0/dog.jpg"},
+		{"dog", 0, Transformation{}, base + "so_0.0/dog.jpg"},
+		{"dog", 0, Transformation{Width: 300}, base + "w_300,so_0.0/dog.jpg"},
+	}
+	for _, v := range thumbs {
+		if got := s.VideoThumbnail(v.publicId, v.atSeconds, v.t); got != v.expected {
+			t.Errorf("wrong thumbnail url. Expect %s, got %s", v.expected, got)
+		}
+	}
+}
+
+func TestAttachmentUrl(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/"
+	urls := []struct {
+		publicId string
+		rtype    ResourceType
+		filename string
+		expected string
+	}{
+		{"docs/report", RawType, "", base + "raw/upload/fl_attachment/docs/report"},
+		{"logo", ImageType, "company-logo.png", base + "image/upload/fl_attachment:company-logo/logo"},
+		{"logo", ImageType, "my logo (v2).pdf", base + "image/upload/fl_attachment:my_logo__v2_/logo"},
+	}
+	for _, u := range urls {
+		if got := s.AttachmentUrl(u.publicId, u.rtype, u.filename); got != u.expected {
+			t.Errorf("wrong attachment url. Expect %s, got %s", u.expected, got)
+		}
+	}
+}
+
+func TestOptimizerUrl(t *testing.T) {
+	s := cloudinaryService()
+	if u := s.OptimizerUrl("https://www.example.com/images/logo.png"); u != "" {
+		t.Errorf("expected no URL without optimizer, got %s", u)
+	}
+	if err := s.OptimizerURI("media.example.com"); err == nil {
+		t.Error("should fail on optimizer URI without scheme")
+	}
+	if err := s.OptimizerURI("https://media.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if u := s.OptimizerUrl("https://www.example.com/images/logo.png"); u != "" {
+		t.Errorf("expected no URL without optimizer transformation, got %s", u)
+	}
+	if err := s.OptimizerTransformation("web/small"); err == nil {
+		t.Error("should fail on invalid named transformation")
+	}
+	if err := s.OptimizerTransformation("web"); err != nil {
+		t.Fatal(err)
+	}
+	urls := [][2]string{
+		// order: remote url, expected result
+		{"https://www.example.com/images/logo.png", "https://media.example.com/t_web/images/logo.png"},
+		{"http://www.example.com/images/logo.png?v=2", "https://media.example.com/t_web/images/logo.png?v=2"},
+		{"https://www.example.com/my%20logo.png", "https://media.example.com/t_web/my%20logo.png"},
+		{"images/logo.png", ""},
+	}
+	for _, u := range urls {
+		if got := s.OptimizerUrl(u[0]); got != u[1] {
+			t.Errorf("wrong optimizer url. Expect %s, got %s", u[1], got)
+		}
+	}
+}
+
+func TestFaceCropUrl(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/image/upload/"
+	crops := []struct {
+		face     []int
+		expected string
+	}{
+		{[]int{120, 45, 80, 96}, base + "c_crop,x_120,y_45,w_80,h_96/c_fill,w_200,h_200/team"},
+		// Faces at the top left corner, left and top edges
+		{[]int{0, 0, 64, 64}, base + "c_crop,x_0,y_0,w_64,h_64/c_fill,w_200,h_200/team"},
+		{[]int{0, 45, 80, 96}, base + "c_crop,x_0,y_45,w_80,h_96/c_fill,w_200,h_200/team"},
+		{[]int{120, 0, 80, 96}, base + "c_crop,x_120,y_0,w_80,h_96/c_fill,w_200,h_200/team"},
+		{[]int{120, 45, 0, 96}, ""},
+		{[]int{120, 45, 80}, ""},
+	}
+	for _, c := range crops {
+		if got := s.FaceCropUrl("team", c.face); got != c.expected {
+			t.Errorf("wrong face crop url for %v. Expect %s, got %s", c.face, c.expected, got)
+		}
+	}
+}
+
+func TestSignedFetchUrl(t *testing.T) {
+	s := cloudinaryService()
+	base := baseResourceUrl + "/cloudname/image/fetch/"
+	remote := "http://example.com/images/logo.png?size=big"
+	escaped := "http://example.com/images/logo.png%3Fsize%3Dbig"
+
+	if got, expected := s.FetchUrl(remote, Transformation{}), base+escaped; got != expected {
+		t.Errorf("wrong fetch url. Expect %s, got %s", expected, got)
+	}
+	if got, expected := s.SignedFetchUrl(remote, Transformation{}), base+"s--fMMNSAsS--/"+escaped; got != expected {
+		t.Errorf("wrong signed fetch url. Expect %s, got %s", expected, got)
+	}
+	tr := Transformation{Crop: "fill", Width: 300, Height: 200}
+	expected := base + "s--TF_OJTbO--/c_fill,w_300,h_200/" + escaped
+	if got := s.SignedFetchUrl(remote, tr); got != expected {
+		t.Errorf("wrong signed fetch url. Expect %s, got %s", expected, got)
+	}
+}
+
+func TestUrlChainedConditional(t *testing.T) {
+	s := cloudinaryService()
+	steps := []Transformation{
+		{If: "w_gt_500", Crop: "fill", Width: 500},
+		{If: "else", Crop: "fit", Width: 200},
+		{If: "end"},
+		{Quality: "auto"},
+	}
+	u, err := s.UrlChained("logo", ImageType, steps)
+	if err != nil {
+		t.Fatal("expected no error to occur", err)
+	}
+	expected := baseResourceUrl + "/cloudname/image/upload/if_w_gt_500,c_fill,w_500/if_else,c_fit,w_200/if_end/q_auto/logo"
+	if u != expected {
+		t.Errorf("wrong chained url. Expect %s, got %s", expected, u)
+	}
+
+	invalid := [][]Transformation{
+		{{If: "w_gt_500", Width: 500}},
+		{{If: "else", Width: 500}, {If: "end"}},
+		{{If: "end"}},
+		{{If: "w_gt_500"}, {If: "h_gt_500"}, {If: "end"}},
+	}
+	for _, steps := range invalid {
+		if _, err := s.UrlChained("logo", ImageType, steps); err == nil {
+			t.Errorf("should fail on unbalanced conditions %v", steps)
+		}
+	}
+}